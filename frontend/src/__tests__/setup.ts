@@ -54,6 +54,17 @@ vi.mock('@/wailsjs/go/execute/CommandExecutor', () => ({
   Abort: vi.fn()
 }))
 
+vi.mock('@/wailsjs/go/execute/InstallSession', () => ({
+  Start: vi.fn(),
+  Snapshot: vi.fn(),
+  Abort: vi.fn()
+}))
+
+vi.mock('@/wailsjs/go/execute/Planner', () => ({
+  Plan: vi.fn(),
+  PlanWith: vi.fn()
+}))
+
 vi.mock('@/wailsjs/go/porter/Porter', () => ({
   Status: vi.fn(),
   Abort: vi.fn(),
@@ -1,28 +1,7 @@
-import { status } from '@/wailsjs/go/models'
-
-export type Command = {
-  id: string | number
-  name?: string
-  groupName: string
-  config: {
-    program: string
-    options: Array<string>
-    minExeTime: number
-    allowRtCodes: Array<number>
-    incompatibles: Array<number>
-  }
-}
+import { execute, status } from '@/wailsjs/go/models'
 
 export type Process = {
-  command: Command
+  command: execute.PlannedCommand
   status: status.Status
-  procId?: string
-  result?: {
-    lapse: number
-    exitCode: number
-    stdout: string
-    stderr: string
-    error: string
-    aborted: boolean
-  }
+  result?: execute.CommandResult
 }
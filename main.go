@@ -3,6 +3,9 @@ package main
 import (
 	"context"
 	"embed"
+	"fmt"
+	"install-it/pkg/cli"
+	"install-it/pkg/event"
 	"install-it/pkg/execute"
 	"install-it/pkg/matching"
 	"install-it/pkg/porter"
@@ -11,6 +14,7 @@ import (
 	"install-it/pkg/sysinfo"
 	"install-it/pkg/update"
 	"os"
+	"os/signal"
 	"path/filepath"
 
 	"github.com/Masterminds/semver"
@@ -18,6 +22,7 @@ import (
 	"github.com/wailsapp/wails/v2/pkg/options"
 	"github.com/wailsapp/wails/v2/pkg/options/assetserver"
 	"github.com/wailsapp/wails/v2/pkg/options/windows"
+	wails_runtime "github.com/wailsapp/wails/v2/pkg/runtime"
 )
 
 //go:embed all:frontend/dist
@@ -29,6 +34,8 @@ var (
 	dirConf string
 	// Path to the driver directory
 	dirDir string
+	// Path to the install log directory
+	dirLog string
 	// Path to the WebView2 executable
 	pathWV2      string
 	buildVersion string
@@ -39,6 +46,7 @@ var (
 	db             *storage.Database
 	groupStorage   *storage.DriverGroupStorage
 	ruleSetStorage *storage.RuleSetStorage
+	historyStorage *storage.InstallHistoryStorage
 	matcher        *matching.Matcher
 )
 
@@ -67,6 +75,9 @@ func init() {
 		os.MkdirAll(filepath.Join(dirDir, sub), os.ModePerm)
 	}
 
+	dirLog = filepath.Join(dirConf, "logs")
+	os.MkdirAll(dirLog, os.ModePerm)
+
 	pathWV2 = filepath.Join(dirRoot, "internals", "bin", "WebView2")
 	if _, err := os.Stat(pathWV2); err != nil {
 		pathWV2 = ""
@@ -75,7 +86,17 @@ func init() {
 
 func main() {
 	app := &App{}
-	mgt := &execute.CommandExecutor{}
+	events := &event.WailsPublisher{}
+	expander := &execute.Expander{
+		Root:    dirRoot,
+		Drivers: dirDir,
+		LogDir:  dirLog,
+	}
+	launchers := execute.NewLaunchers()
+	// Extracted files of failed installers are kept for inspection
+	archives := &execute.Archives{CacheDir: filepath.Join(os.TempDir(), "install-it"), Cleanup: execute.CleanupOnSuccess}
+	logs := &execute.LogStore{Dir: dirLog}
+	mgt := &execute.CommandExecutor{Expander: expander, Launchers: launchers, Archives: archives, Events: events, Logs: logs}
 
 	var err error
 	db, err = storage.Open(filepath.Join(dirConf, "data.db"))
@@ -88,7 +109,19 @@ func main() {
 
 	groupStorage = storage.NewDriverGroupStorage(db)
 	ruleSetStorage = storage.NewRuleSetStorage(db)
+	historyStorage = storage.NewInstallHistoryStorage(db)
 	matcher = matching.NewMatcher(ruleSetStorage, matching.WMIHardwareQuerier{})
+	settingStorage := &storage.AppSettingStorage{Path: filepath.Join(dirConf, "setting.json")}
+	planner := execute.NewPlanner(groupStorage, settingStorage, expander, launchers)
+
+	session := &execute.InstallSession{
+		Runner:    execute.ProcessRunner{Expander: expander, Launchers: launchers, Archives: archives},
+		History:   historyStorage,
+		StatePath: filepath.Join(dirConf, "session.json"),
+		Launcher:  execute.RunOnceLauncher{Name: "install-it"},
+		Events:    events,
+		Logs:      logs,
+	}
 
 	// Porter instance shared between Bind and OnStartup
 	porterInstance := &porter.Porter{
@@ -105,6 +138,28 @@ func main() {
 		},
 	}
 
+	// Run headless subcommands from scripts instead of the GUI
+	if args := os.Args[1:]; cli.IsCommand(args) {
+		cli.AttachConsole()
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		code := cli.Run(ctx, cli.Env{
+			Groups:    groupStorage,
+			Settings:  settingStorage,
+			Expander:  expander,
+			Launchers: launchers,
+			Archives:  archives,
+			Matcher:   matcher,
+			History:   historyStorage,
+			Logs:      logs,
+			Exporter:  porterInstance,
+			Stdout:    os.Stdout,
+			Stderr:    os.Stderr,
+		}, args)
+		stop()
+		db.Close()
+		os.Exit(code)
+	}
+
 	err = wails.Run(&options.App{
 		Title:     "install-it",
 		Width:     768,
@@ -133,16 +188,48 @@ func main() {
 			porterInstance.RecoverOrphanedBackups()
 
 			app.SetContext(ctx)
+			events.SetContext(ctx)
 			mgt.SetContext(ctx)
+
+			// Offer to resume a session interrupted by a reboot
+			go func() {
+				steps, err := session.ResumableSteps()
+				if err != nil || len(steps) == 0 {
+					return
+				}
+
+				choice, err := wails_runtime.MessageDialog(ctx, wails_runtime.MessageDialogOptions{
+					Type:    wails_runtime.QuestionDialog,
+					Title:   "install-it",
+					Message: fmt.Sprintf("An install session was interrupted with %d step(s) left. Resume it?", len(steps)),
+				})
+				switch {
+				case err != nil:
+					// Keep the saved session, so that it is offered again on the next start
+					wails_runtime.LogErrorf(ctx, "cannot ask to resume the interrupted session: %v", err)
+				case choice == "Yes":
+					if err := session.Resume(); err != nil {
+						wails_runtime.LogErrorf(ctx, "cannot resume the interrupted session: %v", err)
+					}
+				case choice == "No":
+					if err := session.Discard(); err != nil {
+						wails_runtime.LogErrorf(ctx, "cannot discard the interrupted session: %v", err)
+					}
+				}
+			}()
 		},
 		Bind: []interface{}{
 			app,
 			mgt,
+			session,
 			updater,
-			&storage.AppSettingStorage{Path: filepath.Join(dirConf, "setting.json")},
+			settingStorage,
 			groupStorage,
 			ruleSetStorage,
+			historyStorage,
 			matcher,
+			planner,
+			logs,
 			porterInstance,
 			&sysinfo.SysInfo{},
 		},
@@ -171,12 +258,24 @@ func main() {
 				{status.Pending, "PENDING"},
 				{status.Running, "RUNNING"},
 				{status.Completed, "COMPLETED"},
+				{status.RebootRequired, "REBOOT_REQUIRED"},
+				{status.Unverified, "UNVERIFIED"},
 				{status.Failed, "FAILED"},
 				{status.Aborting, "ABORTING"},
 				{status.Aborted, "ABORTED"},
 				{status.Skiped, "SKIPED"},
 				{status.Speeded, "SPEEDED"},
 				{status.Errored, "ERRORED"},
+				{status.TimedOut, "TIMED_OUT"},
+			},
+			[]struct {
+				Value  storage.VerifyType
+				TSName string
+			}{
+				{storage.VerifyNone, "NONE"},
+				{storage.VerifyFile, "FILE"},
+				{storage.VerifyCommand, "COMMAND"},
+				{storage.VerifyOutput, "OUTPUT"},
 			},
 			[]struct {
 				Value  storage.RuleSource
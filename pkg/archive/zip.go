@@ -0,0 +1,100 @@
+// Package archive extracts ZIP archives without writing outside of the
+// destination directory (ZipSlip).
+package archive
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrZipSlip is returned for entries that would be extracted outside of the
+// destination directory.
+var ErrZipSlip = errors.New("archive: zip slip detected")
+
+// Target returns the path the entry name is extracted to within dest, or an
+// error wrapping ErrZipSlip if it is outside of dest.
+func Target(dest, name string) (string, error) {
+	cleanDest := filepath.Clean(dest)
+	target := filepath.Join(cleanDest, filepath.FromSlash(name))
+	if target != cleanDest && !strings.HasPrefix(target, cleanDest+string(os.PathSeparator)) {
+		return "", fmt.Errorf("%w: %s", ErrZipSlip, name)
+	}
+	return target, nil
+}
+
+// ExtractFile extracts the entry zf to its target within dest, creating the
+// parent directories, and returns the target.
+func ExtractFile(zf *zip.File, dest string) (string, error) {
+	target, err := Target(dest, zf.Name)
+	if err != nil {
+		return "", err
+	}
+
+	if zf.FileInfo().IsDir() {
+		return target, os.MkdirAll(target, os.ModePerm)
+	}
+	if err := os.MkdirAll(filepath.Dir(target), os.ModePerm); err != nil {
+		return "", fmt.Errorf("archive: cannot create directory %s: %w", filepath.Dir(target), err)
+	}
+
+	reader, err := zf.Open()
+	if err != nil {
+		return "", fmt.Errorf("archive: cannot open entry %s: %w", zf.Name, err)
+	}
+	defer reader.Close()
+
+	out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, zf.Mode())
+	if err != nil {
+		return "", fmt.Errorf("archive: cannot create file %s: %w", target, err)
+	}
+	if _, err := io.Copy(out, reader); err != nil {
+		out.Close()
+		return "", fmt.Errorf("archive: error writing file %s: %w", target, err)
+	}
+	return target, out.Close()
+}
+
+// Extract extracts every entry of the ZIP archive at path to dest. Every
+// entry is checked before anything is written, so that an archive with an
+// illegal entry is not extracted at all.
+func Extract(path, dest string) error {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	for _, zf := range zr.File {
+		if _, err := Target(dest, zf.Name); err != nil {
+			return err
+		}
+	}
+	for _, zf := range zr.File {
+		if _, err := ExtractFile(zf, dest); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Contains reports whether the ZIP archive at path has the file entry name.
+func Contains(path, name string) (bool, error) {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return false, err
+	}
+	defer zr.Close()
+
+	name = strings.TrimPrefix(strings.ReplaceAll(name, `\`, "/"), "/")
+	for _, zf := range zr.File {
+		if !zf.FileInfo().IsDir() && strings.EqualFold(zf.Name, name) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,89 @@
+package archive_test
+
+import (
+	"archive/zip"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"install-it/pkg/archive"
+)
+
+func writeZip(t *testing.T, files map[string]string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "test.zip")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	return path
+}
+
+func TestTarget(t *testing.T) {
+	t.Parallel()
+
+	dest := t.TempDir()
+	for _, name := range []string{"../evil.txt", "a/../../evil.txt"} {
+		if _, err := archive.Target(dest, name); !errors.Is(err, archive.ErrZipSlip) {
+			t.Errorf("Target(%q): got %v, want ErrZipSlip", name, err)
+		}
+	}
+	if got, err := archive.Target(dest, "sub/setup.exe"); err != nil || got != filepath.Join(dest, "sub", "setup.exe") {
+		t.Errorf("Target: got %q, %v", got, err)
+	}
+}
+
+func TestExtract(t *testing.T) {
+	t.Parallel()
+
+	path := writeZip(t, map[string]string{"setup.exe": "exe", "sub/data.bin": "data"})
+	dest := filepath.Join(t.TempDir(), "dest")
+	if err := archive.Extract(path, dest); err != nil {
+		t.Fatalf("Extract: %v", err)
+	}
+	if got, err := os.ReadFile(filepath.Join(dest, "sub", "data.bin")); err != nil || string(got) != "data" {
+		t.Errorf("sub/data.bin: got %q, %v", got, err)
+	}
+}
+
+func TestExtract_ZipSlipWritesNothing(t *testing.T) {
+	t.Parallel()
+
+	path := writeZip(t, map[string]string{"a.txt": "a", "../evil.txt": "pwned"})
+	dir := t.TempDir()
+	dest := filepath.Join(dir, "dest")
+
+	if err := archive.Extract(path, dest); !errors.Is(err, archive.ErrZipSlip) {
+		t.Fatalf("got %v, want ErrZipSlip", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "evil.txt")); !os.IsNotExist(err) {
+		t.Error("entry extracted outside of dest")
+	}
+	if _, err := os.Stat(filepath.Join(dest, "a.txt")); !os.IsNotExist(err) {
+		t.Error("legal entries should not be extracted from an illegal archive")
+	}
+}
+
+func TestContains(t *testing.T) {
+	t.Parallel()
+
+	path := writeZip(t, map[string]string{"Drivers/Setup.exe": "exe"})
+	for name, want := range map[string]bool{"Drivers/Setup.exe": true, `drivers\setup.exe`: true, "Drivers": false, "setup.exe": false} {
+		if got, err := archive.Contains(path, name); err != nil || got != want {
+			t.Errorf("Contains(%q): got %v, %v, want %v", name, got, err, want)
+		}
+	}
+}
@@ -0,0 +1,359 @@
+// Package cli implements the headless subcommands of install-it, so that
+// installs can run unattended from scripts without the GUI.
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"install-it/pkg/event"
+	"install-it/pkg/execute"
+	"install-it/pkg/status"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const usage = `Usage: install-it <command> [flags]
+
+Commands:
+  run     install driver groups and setting tasks
+  match   list the driver groups matching the hardware
+  export  export the configuration and drivers to a ZIP file
+
+Run "install-it <command> -h" for the flags of a command.
+`
+
+// Exit codes of Run.
+const (
+	ExitOk     = 0 // The command succeeded
+	ExitFailed = 1 // The install or the command failed
+	ExitUsage  = 2 // The command line is invalid
+)
+
+// GroupMatcher finds the driver groups matching the hardware.
+// It is satisfied by *matching.Matcher.
+type GroupMatcher interface {
+	MatchedGroupIds() ([]uint, error)
+}
+
+// Exporter exports the program data to a ZIP file.
+// It is satisfied by *porter.Porter.
+type Exporter interface {
+	Export(dest string) error
+}
+
+// Env holds the dependencies of the commands, so that they can run against
+// fakes in tests.
+type Env struct {
+	Groups    execute.GroupReader
+	Settings  execute.SettingReader
+	Expander  *execute.Expander
+	Launchers *execute.Launchers // The built-in installer types when nil
+	Archives  *execute.Archives  // Extracts to the temporary directory when nil
+	Matcher   GroupMatcher
+	Runner    execute.Runner          // ProcessRunner with Expander, Launchers and Archives when nil
+	History   execute.HistoryRecorder // Nothing is recorded when nil
+	Logs      *execute.LogStore       // Keeps the full output of steps, not kept when nil
+	Exporter  Exporter
+	Stdout    io.Writer
+	Stderr    io.Writer
+}
+
+// IsCommand reports whether args, without the program name, start with a
+// subcommand, so that the app runs headless instead of starting the GUI.
+func IsCommand(args []string) bool {
+	return len(args) > 0 && slices.Contains([]string{"run", "match", "export", "help", "-h", "-help", "--help"}, args[0])
+}
+
+// Run runs the subcommand in args, without the program name, and returns the
+// exit code. Cancelling ctx aborts a running install.
+func Run(ctx context.Context, env Env, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(env.Stderr, usage)
+		return ExitUsage
+	}
+
+	var err error
+	switch args[0] {
+	case "run":
+		return runInstall(ctx, env, args[1:])
+	case "match":
+		err = runMatch(env, args[1:])
+	case "export":
+		err = runExport(env, args[1:])
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(env.Stdout, usage)
+		return ExitOk
+	default:
+		fmt.Fprintf(env.Stderr, "install-it: unknown command %q\n\n%s", args[0], usage)
+		return ExitUsage
+	}
+
+	switch {
+	case errors.Is(err, flag.ErrHelp):
+		return ExitOk
+	case errors.Is(err, errUsage):
+		return ExitUsage
+	case err != nil:
+		fmt.Fprintf(env.Stderr, "install-it: %v\n", err)
+		return ExitFailed
+	}
+	return ExitOk
+}
+
+// errUsage is returned by commands for invalid flags, which the flag package
+// has already reported.
+var errUsage = errors.New("cli: invalid usage")
+
+func newFlagSet(env Env, name string) *flag.FlagSet {
+	fs := flag.NewFlagSet("install-it "+name, flag.ContinueOnError)
+	fs.SetOutput(env.Stderr)
+	return fs
+}
+
+func parse(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return errUsage
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintf(fs.Output(), "unexpected arguments: %v\n", fs.Args())
+		return errUsage
+	}
+	return nil
+}
+
+func runInstall(ctx context.Context, env Env, args []string) int {
+	fs := newFlagSet(env, "run")
+	groups := fs.String("groups", "", "comma-separated ids of the driver groups to install")
+	matched := fs.Bool("matched", false, "also install the driver groups matching the hardware")
+	parallel := fs.Int("parallel", -1, "installers to run at once, 0 for no limit (default from the settings)")
+	report := fs.String("report", "", "write the final session state as JSON to this file")
+	dryRun := fs.Bool("dry-run", false, "print the execution preview as JSON instead of installing")
+
+	if err := parse(fs, args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return ExitOk
+		}
+		return ExitUsage
+	}
+
+	groupIds, err := parseIds(*groups)
+	if err != nil {
+		fmt.Fprintf(env.Stderr, "install-it: invalid --groups: %v\n", err)
+		return ExitUsage
+	}
+	if *matched {
+		ids, err := env.Matcher.MatchedGroupIds()
+		if err != nil {
+			fmt.Fprintf(env.Stderr, "install-it: %v\n", err)
+			return ExitFailed
+		}
+		for _, id := range ids {
+			if !slices.Contains(groupIds, id) {
+				groupIds = append(groupIds, id)
+			}
+		}
+	}
+
+	planner := execute.NewPlanner(env.Groups, env.Settings, env.Expander, env.Launchers)
+	if *dryRun {
+		preview, err := planner.DryRun(groupIds)
+		if err == nil {
+			err = writeJson(env.Stdout, preview)
+		}
+		if err != nil {
+			fmt.Fprintf(env.Stderr, "install-it: %v\n", err)
+			return ExitFailed
+		}
+		return ExitOk
+	}
+
+	plan, err := planner.Plan(groupIds)
+	if err != nil {
+		fmt.Fprintf(env.Stderr, "install-it: %v\n", err)
+		return ExitFailed
+	}
+	if *parallel >= 0 {
+		plan.MaxConcurrency = *parallel
+	}
+
+	snapshot, err := install(ctx, env, plan)
+	if err != nil {
+		fmt.Fprintf(env.Stderr, "install-it: %v\n", err)
+		return ExitFailed
+	}
+
+	if *report != "" {
+		file, err := os.Create(*report)
+		if err == nil {
+			err = errors.Join(writeJson(file, snapshot), file.Close())
+		}
+		if err != nil {
+			fmt.Fprintf(env.Stderr, "install-it: report: %v\n", err)
+			return ExitFailed
+		}
+	}
+
+	if snapshot.Status != status.Completed {
+		return ExitFailed
+	}
+	return ExitOk
+}
+
+// install runs plan to completion, printing every finished step, and returns
+// the final state of the session.
+func install(ctx context.Context, env Env, plan execute.InstallPlan) (execute.SessionSnapshot, error) {
+	runner := env.Runner
+	if runner == nil {
+		runner = execute.ProcessRunner{Expander: env.Expander, Launchers: env.Launchers, Archives: env.Archives}
+	}
+	events := event.NewChannelPublisher(len(plan.Commands) + 1)
+	session := &execute.InstallSession{Runner: runner, History: env.History, Events: events, Logs: env.Logs}
+	if err := session.Start(plan.MaxConcurrency, plan.Commands); err != nil {
+		return execute.SessionSnapshot{}, err
+	}
+
+	aborted := false
+	printed := make(map[string]bool)
+	for {
+		select {
+		case <-ctx.Done():
+			if !aborted {
+				aborted = true
+				fmt.Fprintln(env.Stderr, "install-it: aborting")
+				session.AbortAll()
+			}
+			ctx = context.Background()
+		case e := <-events.C:
+			switch data := e.Data[0].(type) {
+			case execute.Step:
+				printStep(env.Stdout, data)
+				printed[data.Command.Id] = true
+			case execute.SessionSnapshot:
+				// Skipped steps never ran, so only the final state has them
+				for _, step := range data.Steps {
+					if !printed[step.Command.Id] {
+						printStep(env.Stdout, step)
+					}
+				}
+				fmt.Fprintf(env.Stdout, "%s\n", data.Status)
+				return data, nil
+			}
+		}
+	}
+}
+
+func printStep(w io.Writer, step execute.Step) {
+	name := step.Command.Name
+	if step.Command.GroupName != "" {
+		name = step.Command.GroupName + " / " + name
+	}
+	line := fmt.Sprintf("[%s] %s", step.Status, name)
+	if step.Result != nil {
+		line += fmt.Sprintf(" (exit %d, %gs)", step.Result.ExitCode, step.Result.Lapse)
+		if info := step.Result.ExitCodeInfo; info.Description != "" && step.Result.ExitCode != 0 {
+			line += " " + info.Description
+		}
+		if step.Result.FailurePattern != "" {
+			line += fmt.Sprintf(" output matched %q", step.Result.FailurePattern)
+		}
+		if step.Result.Error != "" {
+			line += ": " + step.Result.Error
+		}
+		if v := step.Result.Verification; v != nil && !v.Verified {
+			line += ": " + v.Detail
+		}
+		if len(step.Result.Survivors) > 0 {
+			line += fmt.Sprintf(" (processes %v still running)", step.Result.Survivors)
+		}
+	}
+	fmt.Fprintln(w, line)
+}
+
+func runMatch(env Env, args []string) error {
+	fs := newFlagSet(env, "match")
+	asJson := fs.Bool("json", false, "print the matched groups as JSON")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+
+	ids, err := env.Matcher.MatchedGroupIds()
+	if err != nil {
+		return err
+	}
+
+	type group struct {
+		Id   uint   `json:"id"`
+		Name string `json:"name"`
+	}
+	groups := make([]group, len(ids))
+	for i, id := range ids {
+		g, err := env.Groups.Get(id)
+		if err != nil {
+			return err
+		}
+		groups[i] = group{Id: g.Id, Name: g.Name}
+	}
+
+	if *asJson {
+		return writeJson(env.Stdout, groups)
+	}
+	for _, g := range groups {
+		fmt.Fprintf(env.Stdout, "%d\t%s\n", g.Id, g.Name)
+	}
+	return nil
+}
+
+func runExport(env Env, args []string) error {
+	fs := newFlagSet(env, "export")
+	dir := fs.String("to", "", "directory to write the ZIP file to")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if *dir == "" {
+		fmt.Fprintln(env.Stderr, "install-it export: --to is required")
+		return errUsage
+	}
+
+	if err := os.MkdirAll(*dir, os.ModePerm); err != nil {
+		return err
+	}
+	dest := filepath.Join(*dir, "install-it-"+time.Now().Format("20060102-150405")+".zip")
+	if err := env.Exporter.Export(dest); err != nil {
+		return err
+	}
+	fmt.Fprintln(env.Stdout, dest)
+	return nil
+}
+
+// parseIds parses comma-separated group ids.
+func parseIds(s string) ([]uint, error) {
+	ids := []uint{}
+	for _, field := range strings.Split(s, ",") {
+		if field = strings.TrimSpace(field); field == "" {
+			continue
+		}
+		id, err := strconv.ParseUint(field, 10, 0)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, uint(id))
+	}
+	return ids, nil
+}
+
+func writeJson(w io.Writer, v any) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
@@ -0,0 +1,223 @@
+package cli_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"install-it/pkg/cli"
+	"install-it/pkg/execute"
+	"install-it/pkg/status"
+	"install-it/pkg/storage"
+)
+
+type fakeGroups map[uint]storage.DriverGroup
+
+func (f fakeGroups) Get(id uint) (storage.DriverGroup, error) {
+	if group, ok := f[id]; ok {
+		return group, nil
+	}
+	return storage.DriverGroup{}, storage.ErrNotFound
+}
+
+type fakeSettings storage.AppSetting
+
+func (f fakeSettings) All() (storage.AppSetting, error) {
+	return storage.AppSetting(f), nil
+}
+
+type fakeMatcher []uint
+
+func (f fakeMatcher) MatchedGroupIds() ([]uint, error) {
+	return f, nil
+}
+
+// fakeRunner exits every command with the code in exitCodes, 0 by default.
+type fakeRunner struct {
+	mu        sync.Mutex
+	exitCodes map[string]int
+	ran       []string
+}
+
+func (f *fakeRunner) Run(ctx context.Context, cmd execute.PlannedCommand, output *execute.OutputLog) execute.CommandResult {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.ran = append(f.ran, cmd.Id)
+	return execute.CommandResult{ExitCode: f.exitCodes[cmd.Id]}
+}
+
+type fakeExporter struct {
+	dest string
+	err  error
+}
+
+func (f *fakeExporter) Export(dest string) error {
+	f.dest = dest
+	return f.err
+}
+
+func driver(id uint, name string) *storage.Driver {
+	return &storage.Driver{Id: id, Name: name, Path: name + ".exe", AllowRtCodes: []int32{0}}
+}
+
+func newEnv(runner execute.Runner) (cli.Env, *bytes.Buffer, *bytes.Buffer) {
+	var stdout, stderr bytes.Buffer
+	return cli.Env{
+		Groups: fakeGroups{
+			3: {Id: 3, Name: "Audio", Position: 1, Drivers: []*storage.Driver{driver(30, "realtek")}},
+			7: {Id: 7, Name: "Network", Position: 2, Drivers: []*storage.Driver{driver(70, "intel"), driver(71, "wifi")}},
+		},
+		Settings: fakeSettings{ParallelInstall: true, MaxConcurrency: 4},
+		Matcher:  fakeMatcher{7},
+		Runner:   runner,
+		Stdout:   &stdout,
+		Stderr:   &stderr,
+	}, &stdout, &stderr
+}
+
+func TestRun_Install(t *testing.T) {
+	t.Parallel()
+
+	runner := &fakeRunner{}
+	env, stdout, stderr := newEnv(runner)
+	report := filepath.Join(t.TempDir(), "out.json")
+
+	code := cli.Run(context.Background(), env, []string{"run", "--groups", "3,7", "--parallel", "1", "--report", report})
+	if code != cli.ExitOk {
+		t.Fatalf("exit code: got %d, want %d (stderr %q)", code, cli.ExitOk, stderr)
+	}
+	if want := []string{"30", "70", "71"}; !slices.Equal(runner.ran, want) {
+		t.Errorf("ran: got %v, want %v", runner.ran, want)
+	}
+	if out := stdout.String(); !strings.Contains(out, "[completed] Network / wifi (exit 0") || !strings.HasSuffix(out, "completed\n") {
+		t.Errorf("unexpected output %q", out)
+	}
+
+	data, err := os.ReadFile(report)
+	if err != nil {
+		t.Fatalf("read report: %v", err)
+	}
+	var snapshot execute.SessionSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		t.Fatalf("parse report: %v", err)
+	}
+	if snapshot.Status != status.Completed || snapshot.MaxConcurrency != 1 || len(snapshot.Steps) != 3 {
+		t.Errorf("unexpected report: status %s, max concurrency %d, %d steps", snapshot.Status, snapshot.MaxConcurrency, len(snapshot.Steps))
+	}
+}
+
+func TestRun_InstallFailed(t *testing.T) {
+	t.Parallel()
+
+	runner := &fakeRunner{exitCodes: map[string]int{"70": 1}}
+	env, stdout, _ := newEnv(runner)
+
+	if code := cli.Run(context.Background(), env, []string{"run", "--matched"}); code != cli.ExitFailed {
+		t.Errorf("exit code: got %d, want %d", code, cli.ExitFailed)
+	}
+	if out := stdout.String(); !strings.Contains(out, "[failed] Network / intel (exit 1") || !strings.HasSuffix(out, "failed\n") {
+		t.Errorf("unexpected output %q", out)
+	}
+}
+
+func TestRun_InstallDryRun(t *testing.T) {
+	t.Parallel()
+
+	runner := &fakeRunner{}
+	env, stdout, _ := newEnv(runner)
+
+	if code := cli.Run(context.Background(), env, []string{"run", "--groups", "3", "--dry-run"}); code != cli.ExitOk {
+		t.Fatalf("exit code: got %d, want %d", code, cli.ExitOk)
+	}
+	if len(runner.ran) != 0 {
+		t.Errorf("dry run ran %v", runner.ran)
+	}
+	var report execute.DryRunReport
+	if err := json.Unmarshal(stdout.Bytes(), &report); err != nil {
+		t.Fatalf("parse preview: %v", err)
+	}
+	if len(report.Commands) != 1 || report.Commands[0].Command.Id != "30" {
+		t.Errorf("unexpected preview %+v", report.Commands)
+	}
+}
+
+func TestRun_Usage(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		args []string
+		want int
+	}{
+		{"no command", nil, cli.ExitUsage},
+		{"unknown command", []string{"install"}, cli.ExitUsage},
+		{"help", []string{"help"}, cli.ExitOk},
+		{"unknown flag", []string{"run", "--jobs", "2"}, cli.ExitUsage},
+		{"invalid groups", []string{"run", "--groups", "3,x"}, cli.ExitUsage},
+		{"unknown group", []string{"run", "--groups", "5"}, cli.ExitFailed},
+		{"export without dir", []string{"export"}, cli.ExitUsage},
+		{"extra arguments", []string{"match", "3"}, cli.ExitUsage},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env, _, stderr := newEnv(&fakeRunner{})
+			if code := cli.Run(context.Background(), env, tt.args); code != tt.want {
+				t.Errorf("exit code: got %d, want %d (stderr %q)", code, tt.want, stderr)
+			}
+		})
+	}
+}
+
+func TestRun_Match(t *testing.T) {
+	t.Parallel()
+
+	env, stdout, _ := newEnv(&fakeRunner{})
+	if code := cli.Run(context.Background(), env, []string{"match"}); code != cli.ExitOk {
+		t.Fatalf("exit code: got %d, want %d", code, cli.ExitOk)
+	}
+	if got := stdout.String(); got != "7\tNetwork\n" {
+		t.Errorf("output: got %q", got)
+	}
+}
+
+func TestRun_Export(t *testing.T) {
+	t.Parallel()
+
+	dir := filepath.Join(t.TempDir(), "backup")
+	exporter := &fakeExporter{}
+	env, stdout, _ := newEnv(&fakeRunner{})
+	env.Exporter = exporter
+
+	if code := cli.Run(context.Background(), env, []string{"export", "--to", dir}); code != cli.ExitOk {
+		t.Fatalf("exit code: got %d, want %d", code, cli.ExitOk)
+	}
+	if filepath.Dir(exporter.dest) != dir || filepath.Ext(exporter.dest) != ".zip" {
+		t.Errorf("unexpected destination %q", exporter.dest)
+	}
+	if got := strings.TrimSpace(stdout.String()); got != exporter.dest {
+		t.Errorf("output: got %q, want %q", got, exporter.dest)
+	}
+
+	exporter.err = errors.New("disk full")
+	if code := cli.Run(context.Background(), env, []string{"export", "--to", dir}); code != cli.ExitFailed {
+		t.Errorf("exit code on error: got %d, want %d", code, cli.ExitFailed)
+	}
+}
+
+func TestIsCommand(t *testing.T) {
+	t.Parallel()
+
+	if cli.IsCommand(nil) || cli.IsCommand([]string{"--debug"}) {
+		t.Error("GUI arguments reported as a command")
+	}
+	if !cli.IsCommand([]string{"run", "--groups", "1"}) {
+		t.Error("run not reported as a command")
+	}
+}
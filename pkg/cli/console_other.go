@@ -0,0 +1,7 @@
+//go:build !windows
+
+package cli
+
+// AttachConsole does nothing, since programs outside Windows inherit the
+// console of their parent.
+func AttachConsole() {}
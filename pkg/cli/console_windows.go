@@ -0,0 +1,31 @@
+//go:build windows
+
+package cli
+
+import (
+	"os"
+	"syscall"
+)
+
+// attachParentProcess is ATTACH_PARENT_PROCESS of AttachConsole.
+const attachParentProcess = ^uintptr(0)
+
+// AttachConsole attaches to the console of the parent process, since the app
+// is built as a GUI program without one. Standard streams redirected by the
+// caller are kept.
+func AttachConsole() {
+	attach := syscall.NewLazyDLL("kernel32.dll").NewProc("AttachConsole")
+	if ok, _, _ := attach.Call(attachParentProcess); ok == 0 {
+		return
+	}
+	if _, err := os.Stdout.Stat(); err != nil {
+		if out, err := os.OpenFile("CONOUT$", os.O_WRONLY, 0); err == nil {
+			os.Stdout = out
+		}
+	}
+	if _, err := os.Stderr.Stat(); err != nil {
+		if out, err := os.OpenFile("CONOUT$", os.O_WRONLY, 0); err == nil {
+			os.Stderr = out
+		}
+	}
+}
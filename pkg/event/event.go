@@ -0,0 +1,62 @@
+package event
+
+import (
+	"context"
+	"sync"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// Publisher publishes events to listeners such as the frontend.
+// It is satisfied by *WailsPublisher, *ChannelPublisher and Nop.
+type Publisher interface {
+	Publish(name string, data ...any)
+}
+
+// WailsPublisher publishes events to the frontend through the Wails runtime.
+// Events published before SetContext is called are dropped, since the Wails
+// runtime exits the process without an app context.
+type WailsPublisher struct {
+	mu  sync.RWMutex
+	ctx context.Context
+}
+
+func (p *WailsPublisher) SetContext(ctx context.Context) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.ctx = ctx
+}
+
+func (p *WailsPublisher) Publish(name string, data ...any) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.ctx != nil {
+		runtime.EventsEmit(p.ctx, name, data...)
+	}
+}
+
+// Event is an event published to a ChannelPublisher.
+type Event struct {
+	Name string
+	Data []any
+}
+
+// ChannelPublisher sends published events to C, so that they can be received
+// without a Wails runtime, e.g. in tests. Publish blocks while C is full.
+type ChannelPublisher struct {
+	C chan Event
+}
+
+// NewChannelPublisher creates a ChannelPublisher buffering size events.
+func NewChannelPublisher(size int) *ChannelPublisher {
+	return &ChannelPublisher{C: make(chan Event, size)}
+}
+
+func (p *ChannelPublisher) Publish(name string, data ...any) {
+	p.C <- Event{Name: name, Data: data}
+}
+
+// Nop discards published events.
+type Nop struct{}
+
+func (Nop) Publish(name string, data ...any) {}
@@ -0,0 +1,28 @@
+package event_test
+
+import (
+	"testing"
+
+	"install-it/pkg/event"
+)
+
+func TestChannelPublisher(t *testing.T) {
+	p := event.NewChannelPublisher(1)
+	p.Publish("execute:exited", "id", 1)
+
+	got := <-p.C
+	if got.Name != "execute:exited" || len(got.Data) != 2 || got.Data[0] != "id" || got.Data[1] != 1 {
+		t.Errorf("unexpected event: %+v", got)
+	}
+}
+
+func TestWailsPublisher_WithoutContext(t *testing.T) {
+	// Must not reach the Wails runtime, which exits without an app context
+	var p event.WailsPublisher
+	p.Publish("execute:exited", "id")
+}
+
+func TestNop(t *testing.T) {
+	var p event.Publisher = event.Nop{}
+	p.Publish("execute:exited", "id")
+}
@@ -0,0 +1,165 @@
+package execute
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"install-it/pkg/archive"
+	"install-it/pkg/storage"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// CleanupPolicy decides when the files extracted from an archive are removed.
+type CleanupPolicy string
+
+const (
+	CleanupAlways    CleanupPolicy = "always"    // Removed once the command finished
+	CleanupOnSuccess CleanupPolicy = "onSuccess" // Kept after a failed command for inspection
+	CleanupNever     CleanupPolicy = "never"     // Kept and reused by later runs of the same archive
+)
+
+// Archives extracts drivers packaged in ZIP archives, see
+// storage.Driver.ArchiveEntry, to a directory of CacheDir before they run. A
+// driver without a WorkDir runs in the directory of its entry. A nil Archives
+// extracts to the temporary directory of the OS with CleanupAlways.
+type Archives struct {
+	CacheDir string
+	Cleanup  CleanupPolicy // CleanupAlways when empty
+}
+
+// unpack extracts the archive of driver, and returns a copy of driver running
+// the extracted entry with a function removing the extracted files according
+// to the cleanup policy once the command has finished. Drivers without an
+// ArchiveEntry are returned unchanged.
+func (a *Archives) unpack(driver storage.Driver) (storage.Driver, func(CommandResult) error, error) {
+	keep := func(CommandResult) error { return nil }
+	if driver.ArchiveEntry == "" {
+		return driver, keep, nil
+	}
+
+	cacheDir, policy := filepath.Join(os.TempDir(), "install-it"), CleanupAlways
+	if a != nil {
+		cacheDir = a.CacheDir
+		if a.Cleanup != "" {
+			policy = a.Cleanup
+		}
+	}
+
+	info, err := os.Stat(driver.Path)
+	if err != nil {
+		return driver, keep, fmt.Errorf("execute: cannot open archive: %w", err)
+	}
+	if err := os.MkdirAll(cacheDir, os.ModePerm); err != nil {
+		return driver, keep, fmt.Errorf("execute: cannot create cache directory: %w", err)
+	}
+
+	var dir string
+	if policy == CleanupNever {
+		dir, err = extractCached(driver.Path, cacheDir, cacheKey(driver.Path, info))
+	} else {
+		dir, err = extractTemp(driver.Path, cacheDir, cacheKey(driver.Path, info))
+	}
+	if err != nil {
+		return driver, keep, fmt.Errorf("execute: cannot extract %s: %w", driver.Path, err)
+	}
+	remove := func(CommandResult) error { return os.RemoveAll(dir) }
+
+	entry, err := archive.Target(dir, archiveName(driver.ArchiveEntry))
+	if err == nil {
+		_, err = os.Stat(entry)
+	}
+	if err != nil {
+		if policy != CleanupNever {
+			remove(CommandResult{})
+		}
+		return driver, keep, fmt.Errorf("execute: entry %s not found in %s: %w", driver.ArchiveEntry, driver.Path, err)
+	}
+
+	driver.Path, driver.ArchiveEntry = entry, ""
+	if driver.WorkDir == "" {
+		driver.WorkDir = filepath.Dir(entry)
+	}
+
+	switch policy {
+	case CleanupNever:
+		return driver, keep, nil
+	case CleanupOnSuccess:
+		return driver, func(result CommandResult) error {
+			if !succeeded(result.Status) {
+				return nil
+			}
+			return remove(result)
+		}, nil
+	default:
+		return driver, remove, nil
+	}
+}
+
+// extractCached extracts the archive at path to the directory key of cacheDir
+// unless it was extracted before, and returns the directory.
+func extractCached(path, cacheDir, key string) (string, error) {
+	dir := filepath.Join(cacheDir, key)
+	if info, err := os.Stat(dir); err == nil && info.IsDir() {
+		return dir, nil
+	}
+
+	// Extract next to the directory first, so that a concurrent run never sees
+	// a partially extracted archive
+	tmp, err := extractTemp(path, cacheDir, key)
+	if err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp, dir); err != nil {
+		os.RemoveAll(tmp)
+		if info, statErr := os.Stat(dir); statErr == nil && info.IsDir() {
+			return dir, nil
+		}
+		return "", err
+	}
+	return dir, nil
+}
+
+// extractTemp extracts the archive at path to a new directory of cacheDir
+// named after key, and returns the directory.
+func extractTemp(path, cacheDir, key string) (string, error) {
+	dir, err := os.MkdirTemp(cacheDir, key+"-")
+	if err != nil {
+		return "", err
+	}
+	if err := archive.Extract(path, dir); err != nil {
+		return "", errors.Join(err, os.RemoveAll(dir))
+	}
+	return dir, nil
+}
+
+// cacheKey returns the name of the cache directory of the archive at path,
+// which changes when the archive is replaced.
+func cacheKey(path string, info os.FileInfo) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	hash := fnv.New64a()
+	fmt.Fprintf(hash, "%s|%d|%d", strings.ToLower(path), info.Size(), info.ModTime().UnixNano())
+
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	return logFileName(name) + "-" + strconv.FormatUint(hash.Sum64(), 36)
+}
+
+// previewArchive returns a copy of driver whose Path shows the entry within
+// the archive, for displaying a command without extracting it.
+func previewArchive(driver storage.Driver) storage.Driver {
+	if driver.ArchiveEntry != "" {
+		driver.Path = filepath.Join(driver.Path, filepath.FromSlash(archiveName(driver.ArchiveEntry)))
+		driver.ArchiveEntry = ""
+	}
+	return driver
+}
+
+// archiveName converts an entry path as entered by users to the name of the
+// entry within the archive.
+func archiveName(entry string) string {
+	return strings.TrimPrefix(strings.ReplaceAll(entry, `\`, "/"), "/")
+}
@@ -0,0 +1,124 @@
+package execute
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"install-it/pkg/status"
+	"install-it/pkg/storage"
+)
+
+func writeArchive(t *testing.T, files map[string]string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "lan.zip")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	return path
+}
+
+func TestArchives_Unpack(t *testing.T) {
+	path := writeArchive(t, map[string]string{"Setup/setup.exe": "exe", "Setup/data.cab": "cab"})
+
+	tests := []struct {
+		policy CleanupPolicy
+		result status.Status
+		kept   bool
+	}{
+		{"", status.Completed, false},
+		{CleanupAlways, status.Failed, false},
+		{CleanupOnSuccess, status.Completed, false},
+		{CleanupOnSuccess, status.Failed, true},
+		{CleanupNever, status.Completed, true},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.policy)+"/"+string(tt.result), func(t *testing.T) {
+			archives := &Archives{CacheDir: t.TempDir(), Cleanup: tt.policy}
+			driver, cleanup, err := archives.unpack(storage.Driver{Path: path, ArchiveEntry: `Setup\setup.exe`, Flags: []string{"/s"}})
+			if err != nil {
+				t.Fatalf("unpack: %v", err)
+			}
+
+			dir := filepath.Dir(driver.Path)
+			if filepath.Base(driver.Path) != "setup.exe" || driver.WorkDir != dir || driver.ArchiveEntry != "" || len(driver.Flags) != 1 {
+				t.Errorf("unexpected driver: %+v", driver)
+			}
+			if _, err := os.Stat(filepath.Join(dir, "data.cab")); err != nil {
+				t.Errorf("archive not extracted: %v", err)
+			}
+
+			if err := cleanup(CommandResult{Status: tt.result}); err != nil {
+				t.Fatalf("cleanup: %v", err)
+			}
+			if _, err := os.Stat(driver.Path); (err == nil) != tt.kept {
+				t.Errorf("extracted files kept: got %v, want %v", err == nil, tt.kept)
+			}
+		})
+	}
+}
+
+func TestArchives_Unpack_NeverReusesExtraction(t *testing.T) {
+	path := writeArchive(t, map[string]string{"setup.exe": "exe"})
+	archives := &Archives{CacheDir: t.TempDir(), Cleanup: CleanupNever}
+
+	first, _, err := archives.unpack(storage.Driver{Path: path, ArchiveEntry: "setup.exe"})
+	if err != nil {
+		t.Fatalf("unpack: %v", err)
+	}
+	second, _, err := archives.unpack(storage.Driver{Path: path, ArchiveEntry: "setup.exe"})
+	if err != nil {
+		t.Fatalf("unpack: %v", err)
+	}
+	if first.Path != second.Path {
+		t.Errorf("archive extracted twice: %s, %s", first.Path, second.Path)
+	}
+	if entries, _ := os.ReadDir(archives.CacheDir); len(entries) != 1 {
+		t.Errorf("cache should hold one directory, got %d", len(entries))
+	}
+}
+
+func TestArchives_Unpack_Errors(t *testing.T) {
+	path := writeArchive(t, map[string]string{"setup.exe": "exe"})
+	archives := &Archives{CacheDir: t.TempDir()}
+
+	for _, driver := range []storage.Driver{
+		{Path: path, ArchiveEntry: "missing.exe"},
+		{Path: path, ArchiveEntry: "../setup.exe"},
+		{Path: filepath.Join(t.TempDir(), "missing.zip"), ArchiveEntry: "setup.exe"},
+		{Path: writeArchive(t, map[string]string{"../evil.exe": "exe"}), ArchiveEntry: "evil.exe"},
+	} {
+		if _, _, err := archives.unpack(driver); err == nil {
+			t.Errorf("unpack(%s, %s): expected error, got nil", driver.Path, driver.ArchiveEntry)
+		}
+	}
+	if entries, _ := os.ReadDir(archives.CacheDir); len(entries) != 0 {
+		t.Errorf("failed extractions should be removed, got %d directories", len(entries))
+	}
+}
+
+func TestRunPackaged_ExtractionFailure(t *testing.T) {
+	driver := storage.Driver{Path: filepath.Join(t.TempDir(), "lan.zip"), ArchiveEntry: "lan.msi"}
+
+	result := runPackaged(t.Context(), &Archives{CacheDir: t.TempDir()}, nil, driver, &OutputLog{})
+	if result.Status != status.Failed || result.Error == "" {
+		t.Errorf("unexpected result: %+v", result)
+	}
+	if want := "msiexec /i " + filepath.Join(driver.Path, "lan.msi") + " /qn /norestart"; result.CommandLine != want {
+		t.Errorf("command line: got %q, want %q", result.CommandLine, want)
+	}
+}
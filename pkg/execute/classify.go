@@ -0,0 +1,121 @@
+package execute
+
+import (
+	"fmt"
+	"install-it/pkg/status"
+	"install-it/pkg/storage"
+	"regexp"
+	"slices"
+	"sync"
+)
+
+// defaultRebootRtCodes are the exit codes of Windows Installer meaning
+// success with a reboot required (ERROR_SUCCESS_REBOOT_REQUIRED and
+// ERROR_SUCCESS_REBOOT_INITIATED), used when a driver lists none.
+var defaultRebootRtCodes = []int32{3010, 1641}
+
+// Classify derives the status of a finished command from the driver's rules.
+// A command is failed when its output matched any of FailurePatterns or none
+// of SuccessPatterns, whatever its exit code, and unverified when its
+// verification check failed. Otherwise it requires a reboot
+// when its exit code is listed in RebootRtCodes, is failed when its exit code
+// is neither 0 nor listed in AllowRtCodes, and speeded when it exits sooner
+// than MinExeTime.
+func Classify(driver storage.Driver, result CommandResult) status.Status {
+	rebootRtCodes := driver.RebootRtCodes
+	if len(rebootRtCodes) == 0 {
+		rebootRtCodes = defaultRebootRtCodes
+	}
+
+	switch {
+	case result.TimedOut:
+		return status.TimedOut
+	case result.Aborted:
+		return status.Aborted
+	case result.FailurePattern != "", len(driver.SuccessPatterns) > 0 && result.SuccessPattern == "":
+		return status.Failed
+	case result.Verification != nil && !result.Verification.Verified:
+		return status.Unverified
+	case slices.Contains(rebootRtCodes, int32(result.ExitCode)):
+		return status.RebootRequired
+	case result.ExitCode != 0 && !slices.Contains(driver.AllowRtCodes, int32(result.ExitCode)):
+		return status.Failed
+	case result.Lapse < driver.MinExeTime:
+		return status.Speeded
+	default:
+		return status.Completed
+	}
+}
+
+// outputPatterns are the compiled SuccessPatterns and FailurePatterns of a
+// driver, compiled once per run of the driver.
+type outputPatterns struct {
+	success []*regexp.Regexp
+	failure []*regexp.Regexp
+}
+
+// compilePatterns compiles the output patterns of driver, returning nil if it
+// has none.
+func compilePatterns(driver storage.Driver) (*outputPatterns, error) {
+	if len(driver.SuccessPatterns) == 0 && len(driver.FailurePatterns) == 0 {
+		return nil, nil
+	}
+
+	var patterns outputPatterns
+	for _, list := range []struct {
+		sources []string
+		res     *[]*regexp.Regexp
+	}{{driver.SuccessPatterns, &patterns.success}, {driver.FailurePatterns, &patterns.failure}} {
+		for _, source := range list.sources {
+			re, err := regexp.Compile(source)
+			if err != nil {
+				return nil, fmt.Errorf("execute: invalid output pattern %q: %w", source, err)
+			}
+			*list.res = append(*list.res, re)
+		}
+	}
+	return &patterns, nil
+}
+
+// outputMatch records the output patterns matched by the lines of a command,
+// as they are written. A nil outputMatch matches nothing.
+type outputMatch struct {
+	patterns *outputPatterns
+	mu       sync.Mutex // Lines of stdout and stderr are matched concurrently
+	failure  string     // First failure pattern matched
+	success  string     // First success pattern matched
+}
+
+// line matches a decoded line against the patterns not matched yet.
+func (m *outputMatch) line(text string) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	first := func(res []*regexp.Regexp) string {
+		for _, re := range res {
+			if re.MatchString(text) {
+				return re.String()
+			}
+		}
+		return ""
+	}
+	if m.failure == "" {
+		m.failure = first(m.patterns.failure)
+	}
+	if m.success == "" {
+		m.success = first(m.patterns.success)
+	}
+}
+
+// matched returns the first failure and success patterns matched.
+func (m *outputMatch) matched() (failure, success string) {
+	if m == nil {
+		return "", ""
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.failure, m.success
+}
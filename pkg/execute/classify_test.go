@@ -0,0 +1,51 @@
+package execute_test
+
+import (
+	"testing"
+
+	"install-it/pkg/execute"
+	"install-it/pkg/status"
+	"install-it/pkg/storage"
+)
+
+func TestClassify(t *testing.T) {
+	t.Parallel()
+
+	driver := storage.Driver{MinExeTime: 2, AllowRtCodes: []int32{3010}}
+	patterns := storage.Driver{SuccessPatterns: []string{"(?i)success"}, FailurePatterns: []string{"No supported device", "(?i)installation failed"}}
+
+	tests := []struct {
+		name   string
+		driver storage.Driver
+		result execute.CommandResult
+		want   status.Status
+	}{
+		{"exit zero", driver, execute.CommandResult{Lapse: 3}, status.Completed},
+		{"allowed exit code", storage.Driver{AllowRtCodes: []int32{1}}, execute.CommandResult{ExitCode: 1}, status.Completed},
+		{"default reboot code", driver, execute.CommandResult{Lapse: 3, ExitCode: 3010}, status.RebootRequired},
+		{"default reboot code too fast", driver, execute.CommandResult{Lapse: 1, ExitCode: 1641}, status.RebootRequired},
+		{"custom reboot code", storage.Driver{RebootRtCodes: []int32{194}}, execute.CommandResult{ExitCode: 194}, status.RebootRequired},
+		{"custom reboot codes replace defaults", storage.Driver{RebootRtCodes: []int32{194}}, execute.CommandResult{ExitCode: 3010}, status.Failed},
+		{"disallowed exit code", driver, execute.CommandResult{Lapse: 3, ExitCode: 1}, status.Failed},
+		{"start failure", driver, execute.CommandResult{Lapse: -1, ExitCode: -1, Error: "not found"}, status.Failed},
+		{"too fast", driver, execute.CommandResult{Lapse: 1}, status.Speeded},
+		{"aborted", driver, execute.CommandResult{Lapse: 3, ExitCode: 1, Aborted: true}, status.Aborted},
+		{"timed out", driver, execute.CommandResult{Lapse: 3, ExitCode: 1, TimedOut: true}, status.TimedOut},
+		{"default rules", storage.Driver{}, execute.CommandResult{}, status.Completed},
+		{"failure pattern overrides exit code", patterns, execute.CommandResult{SuccessPattern: "(?i)success", FailurePattern: "No supported device"}, status.Failed},
+		{"failure pattern overrides reboot code", patterns, execute.CommandResult{ExitCode: 3010, SuccessPattern: "(?i)success", FailurePattern: "(?i)installation failed"}, status.Failed},
+		{"success pattern missing", patterns, execute.CommandResult{Stdout: "success"}, status.Failed},
+		{"success pattern matched", patterns, execute.CommandResult{SuccessPattern: "(?i)success"}, status.Completed},
+		{"success pattern does not override exit code", patterns, execute.CommandResult{ExitCode: 1, SuccessPattern: "(?i)success"}, status.Failed},
+		{"failure pattern without success patterns", storage.Driver{FailurePatterns: []string{"fatal"}}, execute.CommandResult{FailurePattern: "fatal"}, status.Failed},
+		{"verification failed", driver, execute.CommandResult{Lapse: 3, Verification: &execute.Verification{}}, status.Unverified},
+		{"verification passed", driver, execute.CommandResult{Lapse: 3, Verification: &execute.Verification{Verified: true}}, status.Completed},
+		{"failure pattern overrides verification", patterns, execute.CommandResult{FailurePattern: "(?i)installation failed", Verification: &execute.Verification{Verified: true}}, status.Failed},
+	}
+
+	for _, tt := range tests {
+		if got := execute.Classify(tt.driver, tt.result); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
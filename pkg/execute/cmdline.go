@@ -0,0 +1,45 @@
+package execute
+
+import (
+	"install-it/pkg/storage"
+	"path"
+	"slices"
+	"strings"
+)
+
+// cmdExeLine returns the command line running driver through cmd /s /c or
+// /s /k, as the launcher of batch files does. With /s, cmd strips the first and
+// last quote of the command after /c, so the command is wrapped in one extra
+// pair of quotes, and its arguments are quoted the way cmd parses them rather
+// than with the backslash escapes of the exec package. It returns false for
+// other programs and for cmd without /s, whose callers pass the whole command
+// as one argument, e.g. cmd /c "shutdown /s /t 5".
+func cmdExeLine(driver storage.Driver) (string, bool) {
+	name := strings.ToLower(path.Base(strings.ReplaceAll(driver.Path, `\`, "/")))
+	if name != "cmd" && name != "cmd.exe" {
+		return "", false
+	}
+	i := slices.IndexFunc(driver.Flags, func(flag string) bool {
+		return strings.EqualFold(flag, "/c") || strings.EqualFold(flag, "/k")
+	})
+	if i == -1 || i == len(driver.Flags)-1 || !slices.ContainsFunc(driver.Flags[:i], func(flag string) bool {
+		return strings.EqualFold(flag, "/s")
+	}) {
+		return "", false
+	}
+
+	args := make([]string, 0, len(driver.Flags)+1)
+	for _, arg := range append([]string{driver.Path}, driver.Flags...) {
+		args = append(args, quoteCmdArg(arg))
+	}
+	return strings.Join(args[:i+2], " ") + ` "` + strings.Join(args[i+2:], " ") + `"`, true
+}
+
+// quoteCmdArg quotes arg for cmd if it holds spaces or characters special to
+// cmd. Arguments holding quotes already are passed on verbatim.
+func quoteCmdArg(arg string) string {
+	if arg == "" || !strings.Contains(arg, `"`) && strings.ContainsAny(arg, " \t&|<>^()") {
+		return `"` + arg + `"`
+	}
+	return arg
+}
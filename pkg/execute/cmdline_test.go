@@ -0,0 +1,47 @@
+package execute
+
+import (
+	"install-it/pkg/storage"
+	"testing"
+)
+
+func TestCmdExeLine(t *testing.T) {
+	tests := []struct {
+		name   string
+		driver storage.Driver
+		want   string
+		ok     bool
+	}{
+		{
+			"path with a space and a quoted flag",
+			storage.Driver{Path: "cmd", Flags: []string{"/d", "/s", "/c", `D:\My Drivers\install.bat`, `/log="D:\My Logs\lan.log"`, "/q"}},
+			`cmd /d /s /c ""D:\My Drivers\install.bat" /log="D:\My Logs\lan.log" /q"`,
+			true,
+		},
+		{
+			"path with special characters",
+			storage.Driver{Path: `C:\Windows\System32\cmd.exe`, Flags: []string{"/D", "/S", "/C", `C:\Program Files (x86)\install.bat`}},
+			`C:\Windows\System32\cmd.exe /D /S /C ""C:\Program Files (x86)\install.bat""`,
+			true,
+		},
+		{
+			"special characters",
+			storage.Driver{Path: "cmd", Flags: []string{"/s", "/c", "echo", "a&b", ""}},
+			`cmd /s /c "echo "a&b" """`,
+			true,
+		},
+		{"whole command line without /s", storage.Driver{Path: "cmd", Flags: []string{"/c", "echo hello"}}, "", false},
+		{"shutdown without /s", storage.Driver{Path: "cmd", Flags: []string{"/C", "shutdown /s /t 5"}}, "", false},
+		{"/s after /c", storage.Driver{Path: "cmd", Flags: []string{"/c", "echo", "/s"}}, "", false},
+		{"no command", storage.Driver{Path: "cmd", Flags: []string{"/s", "/c"}}, "", false},
+		{"other program", storage.Driver{Path: "setup.exe", Flags: []string{"/c", "x"}}, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := cmdExeLine(tt.driver)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("got %s, %v, want %s, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
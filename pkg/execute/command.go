@@ -1,9 +1,15 @@
 package execute
 
 import (
-	"bytes"
+	"context"
 	"errors"
+	"fmt"
+	"install-it/pkg/storage"
+	"io"
+	"os"
 	"os/exec"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/saintfish/chardet"
@@ -12,34 +18,133 @@ import (
 )
 
 type Command struct {
-	cmd       *exec.Cmd
-	startTime time.Time
-	stdout    bytes.Buffer
-	stderr    bytes.Buffer
-	stopped   bool
+	cmd         *exec.Cmd
+	driver      storage.Driver // Rules used to classify the result
+	startTime   time.Time
+	stdout      tailBuffer // Last part of the raw stdout
+	stderr      tailBuffer // Last part of the raw stderr
+	output      *OutputLog // Decoded lines of stdout and stderr, filled while running
+	stdoutLines *lineWriter
+	stderrLines *lineWriter
+	usage       usageSampler
+	stopped     bool
+	timedOut    bool
+	survivors   []int32      // Pids of the process tree left running by Stop
+	match       *outputMatch // Output patterns matched by the lines written
 }
 
 func NewCommand(program string, options []string) *Command {
-	wrapper := Command{cmd: exec.Command(program, options...)}
-	wrapper.cmd.Stdout = &wrapper.stdout
-	wrapper.cmd.Stderr = &wrapper.stderr
+	return newCommand(storage.Driver{Path: program, Flags: options}, &OutputLog{})
+}
+
+func newCommand(driver storage.Driver, output *OutputLog) *Command {
+	wrapper := Command{
+		cmd:         exec.Command(driver.Path, driver.Flags...),
+		driver:      driver,
+		stdout:      tailBuffer{limit: outputTailLimit},
+		stderr:      tailBuffer{limit: outputTailLimit},
+		output:      output,
+		stdoutLines: &lineWriter{log: output, stream: "stdout"},
+		stderrLines: &lineWriter{log: output, stream: "stderr"},
+	}
+	wrapper.cmd.Dir = driver.WorkDir
+	wrapper.cmd.SysProcAttr = sysProcAttr(driver)
+	if len(driver.Env) > 0 {
+		// Later entries win, so the driver's variables override the app's
+		wrapper.cmd.Env = append(os.Environ(), driver.Env...)
+	}
+	wrapper.cmd.Stdout = io.MultiWriter(&wrapper.stdout, wrapper.stdoutLines)
+	wrapper.cmd.Stderr = io.MultiWriter(&wrapper.stderr, wrapper.stderrLines)
 	return &wrapper
 }
 
+// matchOutput makes the command match its output lines against patterns, as
+// they are written.
+func (t *Command) matchOutput(patterns *outputPatterns) {
+	if patterns != nil {
+		t.match = &outputMatch{patterns: patterns}
+		t.stdoutLines.match, t.stderrLines.match = t.match, t.match
+	}
+}
+
 func (t *Command) Start() error {
 	t.startTime = time.Now()
 	return t.cmd.Start()
 }
 
 func (t *Command) Wait() error {
+	defer t.flush()
 	return t.cmd.Wait()
 }
 
 func (t *Command) Run() error {
 	t.startTime = time.Now()
+	defer t.flush()
 	return t.cmd.Run()
 }
 
+// supervise runs the command until it exits, sampling the resource usage of
+// its process tree. The command is stopped when ctx is cancelled, or marked as
+// timed out and stopped when the driver's Timeout or IdleTimeout elapses.
+func (t *Command) supervise(ctx context.Context) error {
+	if err := t.Start(); err != nil {
+		return err
+	}
+	pid := int32(t.cmd.Process.Pid)
+	t.usage.sample(pid)
+	sampler := time.NewTicker(sampleInterval)
+	defer sampler.Stop()
+
+	done := make(chan error, 1)
+	go func() { done <- t.Wait() }()
+
+	var deadline, idleCheck <-chan time.Time
+	if t.driver.Timeout > 0 {
+		timer := time.NewTimer(seconds(t.driver.Timeout))
+		defer timer.Stop()
+		deadline = timer.C
+	}
+	if t.driver.IdleTimeout > 0 {
+		ticker := time.NewTicker(min(time.Second, seconds(t.driver.IdleTimeout)))
+		defer ticker.Stop()
+		idleCheck = ticker.C
+	}
+
+	for {
+		select {
+		case err := <-done:
+			return err
+		case <-ctx.Done():
+			// The process may keep running if Stop fails, so always wait for it
+			return errors.Join(t.Stop(), <-done)
+		case <-deadline:
+			t.timedOut = true
+			return errors.Join(fmt.Errorf("execute: timed out after %gs", t.driver.Timeout), t.Stop(), <-done)
+		case <-sampler.C:
+			t.usage.sample(pid)
+		case <-idleCheck:
+			if t.output.idleFor(t.startTime) >= seconds(t.driver.IdleTimeout) {
+				t.timedOut = true
+				return errors.Join(fmt.Errorf("execute: no output for %gs", t.driver.IdleTimeout), t.Stop(), <-done)
+			}
+		}
+	}
+}
+
+// Output returns the decoded output lines written since offset.
+func (t *Command) Output(offset int) []OutputLine {
+	return t.output.Lines(offset)
+}
+
+// flush emits the unterminated last lines once the pipes are closed.
+func (t *Command) flush() {
+	t.stdoutLines.flush()
+	t.stderrLines.flush()
+}
+
+// Stop stops the process of the command and all its descendants. They are
+// asked to exit first, and killed after a grace period. Processes surviving
+// that are reported in the error and the Survivors of the result.
 func (t *Command) Stop() error {
 	if t.cmd.Process == nil {
 		panic("execute: called Stop before command started")
@@ -50,24 +155,25 @@ func (t *Command) Stop() error {
 		return err
 	}
 
-	if children, err := proc.Children(); err != nil {
-		return err
-	} else {
-		var errorChain error = nil
-		for _, p := range children {
-			if err = p.Kill(); err != nil {
-				errorChain = errors.Join(errorChain, err)
-			}
-		}
-
-		if err := proc.Kill(); err != nil {
-			errorChain = errors.Join(errorChain, err)
-		}
-
-		t.stopped = errorChain == nil
+	survivors, err := stopTree(processTree(proc), stopGracePeriod)
+	t.survivors = survivors
+	t.stopped = !slices.Contains(survivors, proc.Pid)
+	if len(survivors) > 0 {
+		err = errors.Join(err, fmt.Errorf("execute: processes %v are still running", survivors))
+	}
+	return err
+}
 
-		return errorChain
+// children returns the child processes of proc, reporting no children as
+// an empty list rather than an error on every OS.
+func children(proc *process.Process) ([]*process.Process, error) {
+	children, err := proc.Children()
+	var exitErr *exec.ExitError
+	if errors.Is(err, process.ErrorNoChildren) || errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		// pgrep, used on Unix, exits with 1 when nothing matched
+		return nil, nil
 	}
+	return children, err
 }
 
 func (t Command) Lapse() float32 {
@@ -77,27 +183,72 @@ func (t Command) Lapse() float32 {
 	return float32(time.Since(t.startTime).Milliseconds()) / 1000
 }
 
-func (t Command) DecodeStdout() string {
-	if s, err := t.DecodeStdPipe(t.stdout); err != nil {
+// result collects the outcome of a finished command, with err being the error
+// returned from running it.
+func (t *Command) result(err error) CommandResult {
+	var errMsg string
+	if err != nil {
+		errMsg = err.Error()
+	}
+
+	result := CommandResult{
+		Lapse:     t.Lapse(),
+		ExitCode:  t.cmd.ProcessState.ExitCode(),
+		Stdout:    t.DecodeStdout(),
+		Stderr:    t.DecodeStderr(),
+		Error:     errMsg,
+		Aborted:   t.stopped && !t.timedOut,
+		TimedOut:  t.timedOut,
+		Truncated: t.stdout.truncated() || t.stderr.truncated(),
+		Survivors: t.survivors,
+	}
+	if t.cmd.Process != nil {
+		result.Usage = t.usage.usage(int32(t.cmd.Process.Pid), t.cmd.ProcessState)
+	}
+	result.FailurePattern, result.SuccessPattern = t.match.matched()
+	result.Status = Classify(t.driver, result)
+	return result
+}
+
+// commandLine returns the command line of the driver for display, quoting
+// arguments that contain spaces.
+func commandLine(driver storage.Driver) string {
+	args := make([]string, 0, len(driver.Flags)+1)
+	for _, arg := range append([]string{driver.Path}, driver.Flags...) {
+		if arg == "" || strings.ContainsAny(arg, " \t") {
+			arg = `"` + arg + `"`
+		}
+		args = append(args, arg)
+	}
+	return strings.Join(args, " ")
+}
+
+// seconds converts seconds as stored in storage.Driver to a time.Duration.
+func seconds(s float32) time.Duration {
+	return time.Duration(float64(s) * float64(time.Second))
+}
+
+func (t *Command) DecodeStdout() string {
+	if s, err := t.DecodeStdPipe(t.stdout.Bytes()); err != nil {
 		return t.stdout.String()
 	} else {
 		return s
 	}
 }
 
-func (t Command) DecodeStderr() string {
-	if s, err := t.DecodeStdPipe(t.stderr); err != nil {
+func (t *Command) DecodeStderr() string {
+	if s, err := t.DecodeStdPipe(t.stderr.Bytes()); err != nil {
 		return t.stderr.String()
 	} else {
 		return s
 	}
 }
 
-func (t Command) DecodeStdPipe(buff bytes.Buffer) (string, error) {
+func (t *Command) DecodeStdPipe(buff []byte) (string, error) {
 	detector := chardet.NewTextDetector()
-	if result, err := detector.DetectBest(buff.Bytes()); err == nil {
+	if result, err := detector.DetectBest(buff); err == nil {
 		if encoding, _ := charset.Lookup(result.Charset); encoding != nil {
-			return encoding.NewDecoder().String(buff.String())
+			return encoding.NewDecoder().String(string(buff))
 		} else {
 			return "", err
 		}
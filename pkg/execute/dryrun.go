@@ -0,0 +1,106 @@
+package execute
+
+import (
+	"errors"
+	"install-it/pkg/archive"
+	"install-it/pkg/status"
+	"os"
+	"os/exec"
+	"slices"
+)
+
+// DryRunCommand is the predicted run of a planned command.
+type DryRunCommand struct {
+	Command     PlannedCommand `json:"command"`
+	Program     string         `json:"program"` // Path after placeholder expansion
+	Args        []string       `json:"args"`    // Flags after placeholder expansion
+	CommandLine string         `json:"commandLine"`
+	WorkDir     string         `json:"workDir"`
+	Missing     bool           `json:"missing"` // The program, the installer file it launches or the archive entry was not found
+	Wave        int            `json:"wave"`    // Round the command is predicted to start in, -1 if it never starts
+	Lane        int            `json:"lane"`    // Slot of the command among the commands of its wave
+}
+
+// DryRunReport is the preview of an install without spawning processes.
+type DryRunReport struct {
+	MaxConcurrency int             `json:"maxConcurrency"` // Running commands at most, 0 for no limit
+	Commands       []DryRunCommand `json:"commands"`       // In the order of the plan
+	Waves          int             `json:"waves"`          // Number of rounds
+	Missing        []string        `json:"missing"`        // Programs and installer files that were not found
+}
+
+// DryRun builds the plan of the groups with groupIds like Plan, and predicts
+// how it runs. Commands are assumed to succeed and to take equally long, so
+// that the commands of a wave start together once the previous wave finished.
+func (p *Planner) DryRun(groupIds []uint) (DryRunReport, error) {
+	plan, err := p.Plan(groupIds)
+	if err != nil {
+		return DryRunReport{}, err
+	}
+
+	report := DryRunReport{MaxConcurrency: plan.MaxConcurrency, Commands: make([]DryRunCommand, len(plan.Commands)), Missing: []string{}}
+	for i, cmd := range plan.Commands {
+		expanded := p.expander.Expand(cmd.Driver)
+		installer := previewArchive(expanded)
+		driver := p.launchers.Launch(installer)
+		missing := driver.Path
+		_, err := exec.LookPath(driver.Path)
+		if err == nil && expanded.ArchiveEntry != "" {
+			missing = installer.Path
+			if found, zipErr := archive.Contains(expanded.Path, expanded.ArchiveEntry); zipErr != nil || !found {
+				err = errors.Join(zipErr, os.ErrNotExist)
+			}
+		} else if err == nil && p.launchers.launched(installer) {
+			missing = installer.Path
+			_, err = os.Stat(installer.Path)
+		}
+		report.Commands[i] = DryRunCommand{
+			Command:     cmd,
+			Program:     driver.Path,
+			Args:        driver.Flags,
+			CommandLine: commandLine(driver),
+			WorkDir:     driver.WorkDir,
+			Missing:     err != nil,
+		}
+		if err != nil && !slices.Contains(report.Missing, missing) {
+			report.Missing = append(report.Missing, missing)
+		}
+	}
+
+	waves, lanes := predict(plan.Commands, plan.MaxConcurrency)
+	for i := range report.Commands {
+		report.Commands[i].Wave, report.Commands[i].Lane = waves[i], lanes[i]
+		report.Waves = max(report.Waves, waves[i]+1)
+	}
+	return report, nil
+}
+
+// predict simulates the scheduling of cmds and returns the wave and lane each
+// command starts in, or -1 for commands that never start.
+func predict(cmds []PlannedCommand, maxConcurrency int) (waves, lanes []int) {
+	steps := make([]*sessionStep, len(cmds))
+	index := make(map[*sessionStep]int, len(cmds))
+	waves, lanes = make([]int, len(cmds)), make([]int, len(cmds))
+	for i, cmd := range cmds {
+		steps[i] = &sessionStep{Step: Step{Command: cmd, Status: status.Pending}}
+		index[steps[i]] = i
+		waves[i], lanes[i] = -1, -1
+	}
+
+	for wave := 0; ; wave++ {
+		lane := 0
+		schedule(steps, maxConcurrency, func(step *sessionStep) {
+			step.Status = status.Running
+			waves[index[step]], lanes[index[step]] = wave, lane
+			lane++
+		})
+		if lane == 0 {
+			return waves, lanes
+		}
+		for _, step := range steps {
+			if step.Status == status.Running {
+				step.Status = status.Completed
+			}
+		}
+	}
+}
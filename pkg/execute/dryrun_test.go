@@ -0,0 +1,138 @@
+package execute_test
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"install-it/pkg/execute"
+	"install-it/pkg/storage"
+)
+
+type fakeGroups map[uint]storage.DriverGroup
+
+func (f fakeGroups) Get(id uint) (storage.DriverGroup, error) {
+	if group, ok := f[id]; ok {
+		return group, nil
+	}
+	return storage.DriverGroup{}, storage.ErrNotFound
+}
+
+type fakeSettings storage.AppSetting
+
+func (f fakeSettings) All() (storage.AppSetting, error) {
+	return storage.AppSetting(f), nil
+}
+
+func TestPlanner_Plan(t *testing.T) {
+	t.Parallel()
+
+	groups := fakeGroups{
+		1: {Id: 1, Name: "Audio", Position: 2, Drivers: []*storage.Driver{{Id: 10}}},
+		2: {Id: 2, Name: "Chipset", Position: 1, Drivers: []*storage.Driver{{Id: 20}}},
+	}
+	settings := fakeSettings{SetPassword: true, Password: "it's", ParallelInstall: true, MaxConcurrency: 3}
+	planner := execute.NewPlanner(groups, settings, nil, nil)
+
+	plan, err := planner.Plan([]uint{1, 2})
+	if err != nil {
+		t.Fatalf("Plan: %v", err)
+	}
+	if got, want := ids(plan.Commands), []string{"set_password", "20", "10"}; !slices.Equal(got, want) {
+		t.Errorf("commands: got %v, want %v", got, want)
+	}
+	if plan.MaxConcurrency != 3 {
+		t.Errorf("max concurrency: got %d, want 3", plan.MaxConcurrency)
+	}
+	if script := plan.Commands[0].Driver.Flags[3]; script != "Set-LocalUser -Name $Env:UserName -Password (ConvertTo-SecureString 'it''s' -AsPlainText -Force)" {
+		t.Errorf("unexpected set_password script %q", script)
+	}
+
+	if _, err := planner.Plan([]uint{3}); err == nil {
+		t.Error("expected error for a nonexistent group, got nil")
+	}
+}
+
+func TestPlanner_PlanWith(t *testing.T) {
+	t.Parallel()
+
+	groups := fakeGroups{1: {Id: 1, Name: "Audio", Drivers: []*storage.Driver{{Id: 10}}}}
+	planner := execute.NewPlanner(groups, fakeSettings{SetPassword: true, ParallelInstall: true}, nil, nil)
+
+	plan, err := planner.PlanWith([]uint{1}, storage.AppSetting{CreatePartition: true})
+	if err != nil {
+		t.Fatalf("PlanWith: %v", err)
+	}
+	if got, want := ids(plan.Commands), []string{"create_partition", "10"}; !slices.Equal(got, want) {
+		t.Errorf("commands: got %v, want %v", got, want)
+	}
+	if plan.MaxConcurrency != 1 {
+		t.Errorf("max concurrency: got %d, want 1", plan.MaxConcurrency)
+	}
+}
+
+func TestPlanner_DryRun(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "setup.exe"), []byte{}, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	groups := fakeGroups{
+		1: {Id: 1, Name: "GPU", MutuallyExclusive: true, Drivers: []*storage.Driver{
+			{Id: 1, Path: "{root}/setup.exe", Flags: []string{"/log={root}/gpu.log"}},
+			{Id: 2, Path: "{root}/missing.exe"},
+		}},
+		2: {Id: 2, Name: "Audio", Position: 1, Drivers: []*storage.Driver{
+			{Id: 3, Path: "{root}/setup.exe", DependsOnIds: []uint{1}},
+		}},
+	}
+	planner := execute.NewPlanner(groups, fakeSettings{ParallelInstall: true}, &execute.Expander{Root: root}, nil)
+
+	report, err := planner.DryRun([]uint{1, 2})
+	if err != nil {
+		t.Fatalf("DryRun: %v", err)
+	}
+
+	gpu, other, audio := report.Commands[0], report.Commands[1], report.Commands[2]
+	if gpu.Program != root+"/setup.exe" || !slices.Equal(gpu.Args, []string{"/log=" + root + "/gpu.log"}) {
+		t.Errorf("unresolved command: %s %v", gpu.Program, gpu.Args)
+	}
+	if !slices.Equal(gpu.Command.Incompatibles, []string{"2"}) {
+		t.Errorf("incompatibles: got %v, want [2]", gpu.Command.Incompatibles)
+	}
+	if !other.Missing || len(report.Missing) != 1 {
+		t.Errorf("missing: got %v", report.Missing)
+	}
+
+	// GPU drivers exclude each other, and audio waits for the first one
+	waves := []int{gpu.Wave, other.Wave, audio.Wave}
+	if !slices.Equal(waves, []int{0, 1, 1}) {
+		t.Errorf("waves: got %v, want [0 1 1]", waves)
+	}
+	if other.Lane == audio.Lane {
+		t.Errorf("commands of the same wave should have different lanes: %d", other.Lane)
+	}
+	if report.Waves != 2 {
+		t.Errorf("total waves: got %d, want 2", report.Waves)
+	}
+}
+
+func TestPlanner_DryRun_Serial(t *testing.T) {
+	t.Parallel()
+
+	groups := fakeGroups{1: {Id: 1, Drivers: []*storage.Driver{{Id: 1}, {Id: 2}, {Id: 3}}}}
+	planner := execute.NewPlanner(groups, fakeSettings{ParallelInstall: false}, nil, nil)
+
+	report, err := planner.DryRun([]uint{1})
+	if err != nil {
+		t.Fatalf("DryRun: %v", err)
+	}
+	for i, cmd := range report.Commands {
+		if cmd.Wave != i || cmd.Lane != 0 {
+			t.Errorf("%s: wave %d lane %d, want wave %d lane 0", cmd.Command.Id, cmd.Wave, cmd.Lane, i)
+		}
+	}
+}
@@ -5,40 +5,110 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"errors"
-	"syscall"
+	"install-it/pkg/event"
+	"install-it/pkg/status"
+	"install-it/pkg/storage"
+	"path/filepath"
+	"sync"
+	"time"
 
 	"github.com/puzpuzpuz/xsync/v3"
-	"github.com/wailsapp/wails/v2/pkg/runtime"
 )
 
 type CommandExecutor struct {
-	ctx      context.Context
-	commands *xsync.MapOf[string, *Command]
+	Expander  *Expander       // Expands placeholders of started commands, none are expanded when nil
+	Launchers *Launchers      // Launches installers by their type, the built-in types when nil
+	Archives  *Archives       // Extracts installers packaged in archives, to the temporary directory when nil
+	Events    event.Publisher // Receives "execute:exited" with the id and result of finished commands, discarded when nil
+	Logs      *LogStore       // Keeps the full output of started commands under <id>/, not kept when nil
+	Retention *Retention      // Limits the finished commands kept, an hour and 100 commands at most when nil
+
+	commands *xsync.MapOf[string, *task]
+}
+
+// task is a command started by Run or RunDriver.
+type task struct {
+	driver    storage.Driver
+	output    *OutputLog
+	ctx       context.Context
+	cancel    context.CancelFunc
+	startedAt time.Time
+
+	mu         sync.Mutex
+	result     *CommandResult // Nil while running
+	finishedAt time.Time
+}
+
+// name returns the name of the driver, or the file name of its path.
+func (t *task) name() string {
+	if t.driver.Name != "" {
+		return t.driver.Name
+	}
+	return filepath.Base(t.driver.Path)
 }
 
 type CommandResult struct {
-	Lapse    float32 `json:"lapse"`
-	ExitCode int     `json:"exitCode"`
-	Stdout   string  `json:"stdout"`
-	Stderr   string  `json:"stderr"`
-	Error    string  `json:"error"`
-	Aborted  bool    `json:"aborted"`
+	CommandLine    string        `json:"commandLine"` // Command line after placeholder expansion
+	Lapse          float32       `json:"lapse"`
+	ExitCode       int           `json:"exitCode"`
+	ExitCodeInfo   ExitCodeInfo  `json:"exitCodeInfo"` // Meaning of ExitCode, zero if unknown
+	Stdout         string        `json:"stdout"`
+	Stderr         string        `json:"stderr"`
+	Error          string        `json:"error"`
+	FailurePattern string        `json:"failurePattern"` // Failure pattern of the driver that matched the output
+	SuccessPattern string        `json:"successPattern"` // Success pattern of the driver that matched the output
+	Aborted        bool          `json:"aborted"`
+	TimedOut       bool          `json:"timedOut"`     // Stopped by the driver's Timeout or IdleTimeout
+	Status         status.Status `json:"status"`       // Outcome classified by Classify
+	Attempts       []Attempt     `json:"attempts"`     // Every run of the command, including retries
+	Usage          ResourceUsage `json:"usage"`        // Resource usage of the process tree of the last attempt
+	Truncated      bool          `json:"truncated"`    // Stdout and Stderr only hold the last part of the output
+	LogFile        string        `json:"logFile"`      // Reference of the full output in the LogStore, empty if not kept
+	Verification   *Verification `json:"verification"` // Outcome of the driver's verification check, nil if it did not run
+	Survivors      []int32       `json:"survivors"`    // Pids of the process tree still running after stopping the command
 }
 
+// SetContext is called with the Wails app context on startup, and forgets
+// every started command. Events are published through Events instead of ctx.
 func (ce *CommandExecutor) SetContext(ctx context.Context) {
-	ce.ctx = ctx
-	ce.commands = xsync.NewMapOf[string, *Command]()
+	ce.commands = xsync.NewMapOf[string, *task]()
 }
 
 func (ce *CommandExecutor) Run(program string, options []string) string {
+	return ce.start(storage.Driver{Path: program, Flags: options})
+}
+
+// RunDriver is like Run, but the result is classified by the driver's rules
+// and the command is retried according to its retry policy.
+func (ce *CommandExecutor) RunDriver(driver storage.Driver) string {
+	return ce.start(driver)
+}
+
+func (ce *CommandExecutor) start(driver storage.Driver) string {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	ce.prune()
 	id := ce.generateId()
-	ce.commands.Store(id, NewCommand(program, options))
+	ce.commands.Store(id, &task{
+		driver:    ce.Expander.Expand(driver),
+		output:    &OutputLog{},
+		ctx:       ctx,
+		cancel:    cancel,
+		startedAt: ce.retention().now(),
+	})
 
 	go ce.dispatch(id)
 
 	return id
 }
 
+// Preview returns the command line the driver would run with, so that the
+// editor can show the result of placeholder expansion and its launcher. The
+// entry of an archive is shown within the path of the archive.
+func (ce *CommandExecutor) Preview(driver storage.Driver) string {
+	return commandLine(ce.Launchers.Launch(previewArchive(ce.Expander.Expand(driver))))
+}
+
 func (ce *CommandExecutor) RunAndOutput(program string, options []string, hideWindow bool) CommandResult {
 	var (
 		errMsg  string
@@ -46,59 +116,88 @@ func (ce *CommandExecutor) RunAndOutput(program string, options []string, hideWi
 	)
 
 	if hideWindow {
-		command.cmd.SysProcAttr = &syscall.SysProcAttr{
-			HideWindow:    true,
-			CreationFlags: 0x08000000,
-		}
+		command.cmd.SysProcAttr = withoutWindow(command.cmd.SysProcAttr)
 	}
 
 	if err := command.Run(); err != nil {
 		errMsg = err.Error()
 	}
 
-	return CommandResult{
-		command.Lapse(),
-		command.cmd.ProcessState.ExitCode(),
-		command.stdout.String(),
-		command.stderr.String(),
-		errMsg,
-		command.stopped,
+	result := CommandResult{
+		CommandLine: commandLine(command.driver),
+		Lapse:       command.Lapse(),
+		ExitCode:    command.cmd.ProcessState.ExitCode(),
+		Stdout:      command.stdout.String(),
+		Stderr:      command.stderr.String(),
+		Error:       errMsg,
+		Aborted:     command.stopped,
+		Truncated:   command.stdout.truncated() || command.stderr.truncated(),
 	}
+	result.ExitCodeInfo = DescribeExitCode(command.driver, result.ExitCode)
+	if command.cmd.Process != nil {
+		result.Usage = command.usage.usage(int32(command.cmd.Process.Pid), command.cmd.ProcessState)
+	}
+	result.Status = Classify(command.driver, result)
+	return result
 }
 
+// Abort stops the command and cancels its pending retries. A failure to stop
+// the process is reported in the Error of its result.
 func (ce *CommandExecutor) Abort(id string) error {
 	if task, ok := ce.commands.Load(id); !ok {
 		return errors.New("execute: id not found")
 	} else {
-		if err := task.Stop(); err != nil {
-			return errors.Join(err, errors.New("execute: abort failed"))
-		}
+		task.cancel()
 		return nil
 	}
 }
 
+// AbortAll stops every running command and cancels their pending retries.
+func (ce *CommandExecutor) AbortAll() {
+	ce.commands.Range(func(id string, task *task) bool {
+		task.cancel()
+		return true
+	})
+}
+
+// Output returns the decoded output lines written by the command since offset,
+// so that the output of a running command can be polled.
+func (ce *CommandExecutor) Output(id string, offset int) ([]OutputLine, error) {
+	if task, ok := ce.commands.Load(id); !ok {
+		return nil, errors.New("execute: id not found")
+	} else {
+		return task.output.Lines(offset), nil
+	}
+}
+
 func (ce *CommandExecutor) dispatch(id string) {
-	command, ok := ce.commands.Load(id)
+	events := ce.Events
+	if events == nil {
+		events = event.Nop{}
+	}
+
+	task, ok := ce.commands.Load(id)
 	if !ok {
-		runtime.EventsEmit(ce.ctx, "execute:exited", id, CommandResult{
-			Error: "execute: id not found",
+		events.Publish("execute:exited", id, CommandResult{
+			Error:  "execute: id not found",
+			Status: status.Errored,
 		})
 		return
 	}
 
-	var errMsg string
-	if err := command.Run(); err != nil {
-		errMsg = err.Error()
-	}
+	defer task.cancel()
 
-	runtime.EventsEmit(ce.ctx, "execute:exited", id, CommandResult{
-		command.Lapse(),
-		command.cmd.ProcessState.ExitCode(),
-		command.DecodeStdout(),
-		command.DecodeStderr(),
-		errMsg,
-		command.stopped,
-	})
+	ref, closeLog, err := ce.Logs.capture(task.output, id, task.name())
+
+	result := runPackaged(task.ctx, ce.Archives, ce.Launchers, task.driver, task.output)
+	result.LogFile = ref
+	if err = errors.Join(err, closeLog()); err != nil && result.Error == "" {
+		result.Error = err.Error()
+	}
+	// Keep the result before publishing, so that it can be polled on the event
+	task.finish(result, ce.retention().now())
+	events.Publish("execute:exited", id, result)
+	ce.prune()
 }
 
 func (ce CommandExecutor) generateId() string {
@@ -1,24 +1,109 @@
 // Package execute_test provides external black-box tests for the execute package.
 //
 // Design notes:
-//   - CommandExecutor.RunAndOutput does not touch ce.ctx or ce.commands, so it
-//     works safely without calling SetContext first.
+//   - CommandExecutor.RunAndOutput does not touch ce.commands, so it works
+//     safely without calling SetContext first.
 //   - CommandExecutor.Run and Abort require SetContext (which initialises the
-//     internal xsync.MapOf). Passing context.Background() is safe: the Wails
-//     runtime.EventsEmit called inside dispatch is a no-op when the context
-//     carries no Wails app state.
-//   - All process-spawning commands use Windows-native paths (cmd.exe) because
-//     this project targets Windows exclusively.
+//     internal xsync.MapOf). Results are received through an
+//     event.ChannelPublisher, so no Wails runtime is needed.
+//   - Asynchronous tests run the test binary itself as a helper process (see
+//     TestHelperProcess), so they run on any OS. Tests of cmd.exe specifics
+//     use Windows-native paths because this project targets Windows.
 package execute_test
 
 import (
 	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"install-it/pkg/event"
 	"install-it/pkg/execute"
+	"install-it/pkg/status"
+	"install-it/pkg/storage"
+
+	"github.com/shirou/gopsutil/v3/process"
 )
 
+// TestHelperProcess is run as a command by the asynchronous tests, acting as
+// given by INSTALL_IT_HELPER.
+func TestHelperProcess(t *testing.T) {
+	switch os.Getenv("INSTALL_IT_HELPER") {
+	case "sleep":
+		// Written without output, which would reset idle timeouts
+		if path := os.Getenv("INSTALL_IT_PIDFILE"); path != "" {
+			os.WriteFile(path, []byte(strconv.Itoa(os.Getpid())), 0o644)
+		}
+		time.Sleep(time.Minute)
+	case "exit":
+		fmt.Println("exiting")
+		os.Exit(3)
+	case "spawn", "spawnTree":
+		// spawnTree runs spawn, which prints the pid of its sleeping child
+		child := exec.Command(os.Args[0], "-test.run=^TestHelperProcess$")
+		child.Env = append(os.Environ(), "INSTALL_IT_HELPER=sleep")
+		if os.Getenv("INSTALL_IT_HELPER") == "spawnTree" {
+			child.Env, child.Stdout = append(os.Environ(), "INSTALL_IT_HELPER=spawn"), os.Stdout
+		}
+		if err := child.Start(); err != nil {
+			os.Exit(1)
+		}
+		if child.Stdout == nil {
+			fmt.Println(child.Process.Pid)
+		}
+		time.Sleep(time.Minute)
+	case "env":
+		dir, _ := os.Getwd()
+		fmt.Println(dir)
+		fmt.Println(os.Getenv("INSTALL_IT_TEST"))
+		os.Exit(0)
+	case "verbose":
+		// An early failure message followed by more than the kept tail
+		fmt.Println("No supported device found")
+		for range 20000 {
+			fmt.Println(strings.Repeat("x", 64))
+		}
+		os.Exit(0)
+	case "version":
+		fmt.Println("version 1.2.3")
+		os.Exit(0)
+	default:
+		t.Skip("helper process")
+	}
+}
+
+// helperDriver returns a driver running TestHelperProcess with action.
+func helperDriver(action string) storage.Driver {
+	return storage.Driver{
+		Path:  os.Args[0],
+		Flags: []string{"-test.run=^TestHelperProcess$"},
+		Env:   []string{"INSTALL_IT_HELPER=" + action},
+	}
+}
+
+// waitExited waits for the next "execute:exited" event.
+func waitExited(t *testing.T, events *event.ChannelPublisher) (string, execute.CommandResult) {
+	t.Helper()
+	select {
+	case e := <-events.C:
+		if e.Name != "execute:exited" || len(e.Data) != 2 {
+			t.Fatalf("unexpected event: %+v", e)
+		}
+		return e.Data[0].(string), e.Data[1].(execute.CommandResult)
+	case <-time.After(30 * time.Second):
+		t.Fatal("timed out waiting for execute:exited")
+		return "", execute.CommandResult{}
+	}
+}
+
 // ==================== RunAndOutput ====================
 
 func TestCommandExecutor_RunAndOutput_Success(t *testing.T) {
@@ -89,6 +174,36 @@ func TestCommandResult_Lapse(t *testing.T) {
 	}
 }
 
+// ==================== Output ====================
+
+func TestCommand_Output_AvailableAfterRun(t *testing.T) {
+	t.Parallel()
+
+	command := execute.NewCommand("cmd", []string{"/c", "echo first&& echo second 1>&2"})
+	if err := command.Run(); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	lines := command.Output(0)
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %+v", lines)
+	}
+	if len(command.Output(1)) != 1 {
+		t.Errorf("Output(1) should only return the second line, got %+v", command.Output(1))
+	}
+}
+
+func TestCommandExecutor_Output_NonExistentId(t *testing.T) {
+	t.Parallel()
+
+	var ce execute.CommandExecutor
+	ce.SetContext(context.Background())
+
+	if _, err := ce.Output("nonexistent_id", 0); err == nil {
+		t.Error("expected error when reading output of non-existent id, got nil")
+	}
+}
+
 // ==================== Abort ====================
 
 func TestCommandExecutor_Abort_NonExistentId(t *testing.T) {
@@ -103,20 +218,437 @@ func TestCommandExecutor_Abort_NonExistentId(t *testing.T) {
 }
 
 func TestCommandExecutor_Abort_RunningCommand(t *testing.T) {
-	// Skipped: CommandExecutor.Run starts a goroutine that calls dispatch(),
-	// which calls runtime.EventsEmit(ce.ctx, ...).  With context.Background()
-	// Wails calls log.Fatal("invalid context"), crashing the entire test binary.
-	// This path requires a real Wails app context and is covered by integration tests.
-	t.Skip("Run() goroutine calls runtime.EventsEmit which requires a real Wails context — use integration environment")
+	t.Parallel()
+
+	events := event.NewChannelPublisher(1)
+	ce := execute.CommandExecutor{Events: events}
+	ce.SetContext(context.Background())
+
+	id := ce.RunDriver(helperDriver("sleep"))
+	if err := ce.Abort(id); err != nil {
+		t.Fatalf("Abort: %v", err)
+	}
+
+	gotId, result := waitExited(t, events)
+	if gotId != id {
+		t.Errorf("id: got %q, want %q", gotId, id)
+	}
+	if !result.Aborted || result.Status != status.Aborted {
+		t.Errorf("expected an aborted result, got %+v", result)
+	}
+}
+
+func TestProcessRunner_AbortStopsProcessTree(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	output := &execute.OutputLog{}
+	done := make(chan execute.CommandResult, 1)
+	go func() {
+		done <- execute.ProcessRunner{}.Run(ctx, execute.PlannedCommand{Driver: helperDriver("spawnTree")}, output)
+	}()
+
+	// The helper prints the pid of the child of its child once started
+	var grandchild int32
+	for deadline := time.Now().Add(30 * time.Second); grandchild == 0; time.Sleep(50 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the child of the helper")
+		}
+		if lines := output.Lines(0); len(lines) > 0 {
+			pid, err := strconv.Atoi(strings.TrimSpace(lines[0].Text))
+			if err != nil {
+				t.Fatalf("unexpected output %q", lines[0].Text)
+			}
+			grandchild = int32(pid)
+		}
+	}
+	cancel()
+
+	result := <-done
+	if result.Status != status.Aborted || len(result.Survivors) != 0 {
+		t.Errorf("expected an aborted result without survivors, got %+v", result)
+	}
+	if p, err := process.NewProcess(grandchild); err == nil {
+		if st, _ := p.Status(); !slices.Contains(st, process.Zombie) {
+			p.Kill()
+			t.Errorf("grandchild %d is still running", grandchild)
+		}
+	}
+}
+
+func TestCommandExecutor_AbortAll(t *testing.T) {
+	t.Parallel()
+
+	events := event.NewChannelPublisher(2)
+	ce := execute.CommandExecutor{Events: events}
+	ce.SetContext(context.Background())
+
+	ce.RunDriver(helperDriver("sleep"))
+	ce.RunDriver(helperDriver("sleep"))
+	ce.AbortAll()
+
+	for range 2 {
+		if _, result := waitExited(t, events); result.Status != status.Aborted {
+			t.Errorf("expected an aborted result, got %+v", result)
+		}
+	}
 }
 
-// ==================== Run (event emission skipped — requires Wails runtime) ====================
+// ==================== Run ====================
 
 func TestCommandExecutor_Run_ReturnsNonEmptyId(t *testing.T) {
-	t.Skip("Run() dispatches via Wails EventsEmit; requires integration environment with Wails runtime context")
+	t.Parallel()
+
+	events := event.NewChannelPublisher(1)
+	ce := execute.CommandExecutor{Events: events}
+	ce.SetContext(context.Background())
+
+	id := ce.RunDriver(helperDriver("exit"))
+	if id == "" {
+		t.Fatal("expected a non-empty id")
+	}
+	if gotId, result := waitExited(t, events); gotId != id || result.ExitCode != 3 {
+		t.Errorf("unexpected exit event %q: %+v", gotId, result)
+	}
 }
 
-// TestCommandExecutor_RunWailsEvents skips tests that require a real Wails context.
+// TestCommandExecutor_RunWailsEvents checks that commands run headless
+// without any event publisher.
 func TestCommandExecutor_RunWailsEvents(t *testing.T) {
-	t.Skip("Wails runtime.EventsEmit requires a real Wails app context — tested via integration tests only")
+	t.Parallel()
+
+	var ce execute.CommandExecutor
+	ce.SetContext(context.Background())
+
+	id := ce.RunDriver(helperDriver("sleep"))
+	if err := ce.Abort(id); err != nil {
+		t.Fatalf("Abort: %v", err)
+	}
+}
+
+// ==================== Timeout ====================
+
+func TestProcessRunner_Run_Timeout(t *testing.T) {
+	t.Parallel()
+
+	driver, pidFile := sleepDriver(t)
+	driver.Timeout = 1
+	assertTimedOut(t, driver, pidFile)
+}
+
+func TestProcessRunner_Run_IdleTimeout(t *testing.T) {
+	t.Parallel()
+
+	driver, pidFile := sleepDriver(t)
+	driver.IdleTimeout = 1
+	assertTimedOut(t, driver, pidFile)
+}
+
+// sleepDriver returns a driver running the sleep helper, which writes its pid
+// to the returned file.
+func sleepDriver(t *testing.T) (storage.Driver, string) {
+	pidFile := filepath.Join(t.TempDir(), "pid")
+	driver := helperDriver("sleep")
+	driver.Env = append(driver.Env, "INSTALL_IT_PIDFILE="+pidFile)
+	return driver, pidFile
+}
+
+// assertTimedOut runs driver and checks that it timed out, and that the helper
+// whose pid is in pidFile is gone.
+func assertTimedOut(t *testing.T, driver storage.Driver, pidFile string) {
+	t.Helper()
+
+	result := execute.ProcessRunner{}.Run(context.Background(), execute.PlannedCommand{Driver: driver}, &execute.OutputLog{})
+	if !result.TimedOut || result.Aborted || result.Status != status.TimedOut {
+		t.Errorf("expected a timed out result, got %+v", result)
+	}
+	if len(result.Survivors) != 0 {
+		t.Errorf("survivors: got %v, want none", result.Survivors)
+	}
+
+	data, err := os.ReadFile(pidFile)
+	if err != nil {
+		t.Fatalf("helper did not start: %v", err)
+	}
+	pid, _ := strconv.Atoi(string(data))
+	if p, err := process.NewProcess(int32(pid)); err == nil {
+		if st, _ := p.Status(); !slices.Contains(st, process.Zombie) {
+			p.Kill()
+			t.Errorf("helper %d is still running", pid)
+		}
+	}
+}
+
+// ==================== Retry ====================
+
+func TestProcessRunner_Run_RetriesFailedAttempts(t *testing.T) {
+	t.Parallel()
+
+	driver := helperDriver("exit")
+	driver.Retry = storage.RetryPolicy{MaxAttempts: 3, ExitCodes: []int32{3}}
+	cmd := execute.PlannedCommand{Driver: driver}
+	result := execute.ProcessRunner{}.Run(context.Background(), cmd, &execute.OutputLog{})
+
+	if len(result.Attempts) != 3 {
+		t.Fatalf("attempts: got %d, want 3", len(result.Attempts))
+	}
+	if result.Status != status.Failed {
+		t.Errorf("status: got %q, want %q", result.Status, status.Failed)
+	}
+}
+
+func TestProcessRunner_Run_NonRetryableExitCode(t *testing.T) {
+	t.Parallel()
+
+	driver := helperDriver("exit")
+	driver.Retry = storage.RetryPolicy{MaxAttempts: 3, ExitCodes: []int32{5}}
+	cmd := execute.PlannedCommand{Driver: driver}
+	result := execute.ProcessRunner{}.Run(context.Background(), cmd, &execute.OutputLog{})
+
+	if len(result.Attempts) != 1 {
+		t.Errorf("attempts: got %d, want 1", len(result.Attempts))
+	}
+}
+
+// ==================== Output patterns ====================
+
+func TestProcessRunner_Run_FailurePatternEarlyInLongOutput(t *testing.T) {
+	t.Parallel()
+
+	driver := helperDriver("verbose")
+	driver.FailurePatterns = []string{"No supported device"}
+	result := execute.ProcessRunner{}.Run(context.Background(), execute.PlannedCommand{Driver: driver}, &execute.OutputLog{})
+
+	if !result.Truncated || strings.Contains(result.Stdout, "No supported device") {
+		t.Fatalf("the failure message should have left the kept output")
+	}
+	if result.Status != status.Failed || result.FailurePattern != "No supported device" {
+		t.Errorf("got status %q with failure pattern %q", result.Status, result.FailurePattern)
+	}
+}
+
+func TestProcessRunner_Run_InvalidPattern(t *testing.T) {
+	t.Parallel()
+
+	driver := helperDriver("exit")
+	driver.SuccessPatterns = []string{"("}
+	result := execute.ProcessRunner{}.Run(context.Background(), execute.PlannedCommand{Driver: driver}, &execute.OutputLog{})
+
+	if result.Status != status.Failed || len(result.Attempts) != 0 || !strings.Contains(result.Error, "invalid output pattern") {
+		t.Errorf("expected a failure without running, got %+v", result)
+	}
+}
+
+// ==================== WorkDir / Env ====================
+
+func TestProcessRunner_Run_WorkDirAndEnv(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	driver := helperDriver("env")
+	driver.WorkDir = dir
+	driver.Env = append(driver.Env, "INSTALL_IT_TEST=from_driver")
+	cmd := execute.PlannedCommand{Driver: driver}
+	output := &execute.OutputLog{}
+	result := execute.ProcessRunner{}.Run(context.Background(), cmd, output)
+
+	if result.ExitCode != 0 {
+		t.Fatalf("exit code: got %d, want 0 (error %q)", result.ExitCode, result.Error)
+	}
+	lines := output.Lines(0)
+	if len(lines) != 2 {
+		t.Fatalf("output lines: got %d, want 2", len(lines))
+	}
+	if !strings.EqualFold(strings.TrimSpace(lines[0].Text), dir) {
+		t.Errorf("working directory: got %q, want %q", lines[0].Text, dir)
+	}
+	if strings.TrimSpace(lines[1].Text) != "from_driver" {
+		t.Errorf("environment variable: got %q, want %q", lines[1].Text, "from_driver")
+	}
+}
+
+// ==================== Launchers ====================
+
+func TestProcessRunner_Run_BatchPathWithSpaceAndQuotedFlag(t *testing.T) {
+	t.Parallel()
+	if runtime.GOOS != "windows" {
+		t.Skip("runs a batch file through cmd.exe")
+	}
+
+	dir := filepath.Join(t.TempDir(), "My Drivers")
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	script := filepath.Join(dir, "install.bat")
+	if err := os.WriteFile(script, []byte("@echo %1\r\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := execute.PlannedCommand{Driver: storage.Driver{Path: script, Flags: []string{`/log="C:\My Logs\lan.log"`}}}
+	output := &execute.OutputLog{}
+	result := execute.ProcessRunner{}.Run(context.Background(), cmd, output)
+
+	lines := output.Lines(0)
+	if result.ExitCode != 0 || len(lines) != 1 || strings.TrimSpace(lines[0].Text) != `/log="C:\My Logs\lan.log"` {
+		t.Errorf("got exit code %d with output %+v (error %q)", result.ExitCode, lines, result.Error)
+	}
+}
+
+// ==================== Verification ====================
+
+func TestProcessRunner_Verify(t *testing.T) {
+	t.Parallel()
+
+	installed := t.TempDir() + "/installed.txt"
+	if err := os.WriteFile(installed, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	helper := helperDriver("version")
+
+	tests := []struct {
+		name   string
+		check  storage.VerifyCheck
+		want   status.Status
+		detail string
+	}{
+		{"file exists", storage.VerifyCheck{Type: storage.VerifyFile, Path: installed}, status.Completed, "exists"},
+		{"file missing", storage.VerifyCheck{Type: storage.VerifyFile, Path: installed + ".missing"}, status.Unverified, "does not exist"},
+		{"command succeeds", storage.VerifyCheck{Type: storage.VerifyCommand, Path: helper.Path, Flags: helper.Flags}, status.Completed, "exited with 0"},
+		{"command not found", storage.VerifyCheck{Type: storage.VerifyCommand, Path: "__nonexistent_binary_xyz__"}, status.Unverified, "could not run"},
+		{"output matches", storage.VerifyCheck{Type: storage.VerifyOutput, Path: helper.Path, Flags: helper.Flags, Pattern: `version \d+\.\d+`}, status.Completed, "matches"},
+		{"output does not match", storage.VerifyCheck{Type: storage.VerifyOutput, Path: helper.Path, Flags: helper.Flags, Pattern: "version 2"}, status.Unverified, "does not match"},
+		{"invalid pattern", storage.VerifyCheck{Type: storage.VerifyOutput, Path: helper.Path, Pattern: "("}, status.Unverified, "invalid pattern"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			driver := helperDriver("version")
+			driver.Verify = tt.check
+			result := execute.ProcessRunner{}.Run(context.Background(), execute.PlannedCommand{Driver: driver}, &execute.OutputLog{})
+
+			if result.Status != tt.want {
+				t.Errorf("status: got %q, want %q (error %q)", result.Status, tt.want, result.Error)
+			}
+			if result.Verification == nil {
+				t.Fatal("verification did not run")
+			}
+			if result.Verification.Verified != (tt.want == status.Completed) || !strings.Contains(result.Verification.Detail, tt.detail) {
+				t.Errorf("verification: got %+v, want detail containing %q", result.Verification, tt.detail)
+			}
+		})
+	}
+}
+
+func TestProcessRunner_VerifySkippedOnFailure(t *testing.T) {
+	t.Parallel()
+
+	driver := helperDriver("exit")
+	driver.Verify = storage.VerifyCheck{Type: storage.VerifyFile, Path: t.TempDir()}
+	result := execute.ProcessRunner{}.Run(context.Background(), execute.PlannedCommand{Driver: driver}, &execute.OutputLog{})
+
+	if result.Status != status.Failed || result.Verification != nil {
+		t.Errorf("got status %q with verification %+v, want a failed unverified result", result.Status, result.Verification)
+	}
+}
+
+// ==================== Results ====================
+
+func TestCommandExecutor_Result(t *testing.T) {
+	t.Parallel()
+
+	events := event.NewChannelPublisher(1)
+	ce := execute.CommandExecutor{Events: events}
+	ce.SetContext(context.Background())
+
+	id := ce.RunDriver(helperDriver("sleep"))
+	state, err := ce.Result(id)
+	if err != nil {
+		t.Fatalf("Result: %v", err)
+	}
+	if state.Id != id || state.Status != status.Running || state.Result != nil || state.StartedAt.IsZero() {
+		t.Errorf("unexpected state of a running command: %+v", state)
+	}
+	if err := ce.Forget(id); err == nil {
+		t.Error("expected an error when forgetting a running command")
+	}
+
+	ce.Abort(id)
+	_, result := waitExited(t, events)
+	state, _ = ce.Result(id)
+	if state.Status != status.Aborted || state.Result == nil || state.Result.Status != result.Status || state.FinishedAt.IsZero() {
+		t.Errorf("unexpected state of a finished command: %+v", state)
+	}
+
+	if err := ce.Forget(id); err != nil {
+		t.Fatalf("Forget: %v", err)
+	}
+	if _, err := ce.Result(id); err == nil {
+		t.Error("expected an error for a forgotten command")
+	}
+	if err := ce.Forget(id); err == nil {
+		t.Error("expected an error when forgetting twice")
+	}
+}
+
+func TestCommandExecutor_List(t *testing.T) {
+	t.Parallel()
+
+	events := event.NewChannelPublisher(2)
+	ce := execute.CommandExecutor{Events: events}
+	ce.SetContext(context.Background())
+
+	first := ce.RunDriver(helperDriver("exit"))
+	waitExited(t, events)
+	second := ce.RunDriver(helperDriver("exit"))
+	waitExited(t, events)
+
+	states := ce.List()
+	if len(states) != 2 || states[0].Id != first || states[1].Id != second {
+		t.Fatalf("expected both commands in start order, got %+v", states)
+	}
+	if states[0].Status != status.Failed || states[0].Result.ExitCode != 3 {
+		t.Errorf("unexpected state: %+v", states[0])
+	}
+}
+
+func TestCommandExecutor_Retention(t *testing.T) {
+	t.Parallel()
+
+	// The clock is read by the goroutines of the commands too
+	var elapsed atomic.Int64
+	start := time.Date(2026, 10, 17, 8, 0, 0, 0, time.UTC)
+	events := event.NewChannelPublisher(3)
+	ce := execute.CommandExecutor{Events: events, Retention: &execute.Retention{
+		MaxAge:   time.Minute,
+		MaxCount: 2,
+		Now:      func() time.Time { return start.Add(time.Duration(elapsed.Load())) },
+	}}
+	ce.SetContext(context.Background())
+
+	var ids []string
+	for range 3 {
+		ids = append(ids, ce.RunDriver(helperDriver("exit")))
+		waitExited(t, events)
+		elapsed.Add(int64(time.Second))
+	}
+	if _, err := ce.Result(ids[0]); err == nil {
+		t.Error("the oldest command should be forgotten beyond MaxCount")
+	}
+	if len(ce.List()) != 2 {
+		t.Errorf("expected 2 commands kept, got %+v", ce.List())
+	}
+
+	elapsed.Add(int64(2 * time.Minute))
+	if _, err := ce.Result(ids[2]); err == nil {
+		t.Error("Result should not return a command past MaxAge")
+	}
+
+	running := ce.RunDriver(helperDriver("sleep"))
+	if states := ce.List(); len(states) != 1 || states[0].Id != running {
+		t.Errorf("only the running command should be kept after MaxAge, got %+v", states)
+	}
+	ce.Abort(running)
+	waitExited(t, events)
 }
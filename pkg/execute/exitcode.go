@@ -0,0 +1,122 @@
+package execute
+
+import (
+	"fmt"
+	"install-it/pkg/storage"
+)
+
+// ExitCodeInfo is the meaning of an exit code.
+type ExitCodeInfo struct {
+	Name        string `json:"name"` // Symbolic name, e.g. ERROR_INSTALL_FAILURE, empty if there is none
+	Description string `json:"description"`
+	Hint        string `json:"hint"`   // What to check or try, empty if there is nothing to suggest
+	Source      string `json:"source"` // driver, msi, win32, hresult, ntstatus or installshield
+}
+
+// DescribeExitCode returns the meaning of code for driver, from the driver's
+// ExitCodeNotes first and the built-in dictionary otherwise. It returns a zero
+// ExitCodeInfo for unknown codes and for -1, which is reported for commands
+// that did not exit by themselves.
+func DescribeExitCode(driver storage.Driver, code int) ExitCodeInfo {
+	if code == -1 {
+		return ExitCodeInfo{}
+	}
+
+	key := uint32(code)
+	for _, note := range driver.ExitCodeNotes {
+		if uint32(note.Code) == key {
+			return ExitCodeInfo{Description: note.Description, Hint: note.Hint, Source: "driver"}
+		}
+	}
+
+	if info, ok := exitCodes[key]; ok {
+		return info
+	}
+	// HRESULT_FROM_WIN32 wraps Win32 and Windows Installer errors into 0x8007xxxx
+	if key&0xFFFF0000 == 0x80070000 {
+		if info, ok := exitCodes[key&0xFFFF]; ok {
+			info.Name = fmt.Sprintf("HRESULT_FROM_WIN32(%s)", info.Name)
+			info.Source = "hresult"
+			return info
+		}
+	}
+	return ExitCodeInfo{}
+}
+
+// DescribeExitCode returns the meaning of code for driver, so that codes of
+// past runs in the history can be explained too.
+func (ce *CommandExecutor) DescribeExitCode(driver storage.Driver, code int) ExitCodeInfo {
+	return DescribeExitCode(driver, code)
+}
+
+// exitCodes is the built-in dictionary of exit codes by their 32-bit value.
+// Codes of Inno Setup overlap with Win32 errors, so their meaning is given in
+// the hint of the Win32 error.
+var exitCodes = map[uint32]ExitCodeInfo{
+	// Win32 errors
+	1:    {"ERROR_INVALID_FUNCTION", "Incorrect function.", "Inno Setup installers return 1 when Setup failed to initialize.", "win32"},
+	2:    {"ERROR_FILE_NOT_FOUND", "The system cannot find the file specified.", "Check the path of the installer and its files. Inno Setup installers return 2 when the user cancelled before installing.", "win32"},
+	3:    {"ERROR_PATH_NOT_FOUND", "The system cannot find the path specified.", "Check the path and the working directory. Inno Setup installers return 3 for a fatal error while preparing.", "win32"},
+	4:    {"ERROR_TOO_MANY_OPEN_FILES", "The system cannot open the file.", "Inno Setup installers return 4 for a fatal error while installing.", "win32"},
+	5:    {"ERROR_ACCESS_DENIED", "Access is denied.", "Run the app as administrator, and check that no antivirus software blocks the installer. Inno Setup installers return 5 when the user cancelled while installing.", "win32"},
+	6:    {"ERROR_INVALID_HANDLE", "The handle is invalid.", "Inno Setup installers return 6 when Setup was terminated by a debugger.", "win32"},
+	7:    {"ERROR_ARENA_TRASHED", "The storage control blocks were destroyed.", "Inno Setup installers return 7 when the preparing step determined Setup cannot proceed.", "win32"},
+	8:    {"ERROR_NOT_ENOUGH_MEMORY", "Not enough memory resources are available to process this command.", "Inno Setup installers return 8 when a restart is required before Setup can proceed.", "win32"},
+	32:   {"ERROR_SHARING_VIOLATION", "The process cannot access the file because it is being used by another process.", "Close programs using the files, or retry after a reboot.", "win32"},
+	87:   {"ERROR_INVALID_PARAMETER", "The parameter is incorrect.", "Check the flags of the driver.", "win32"},
+	112:  {"ERROR_DISK_FULL", "There is not enough space on the disk.", "Free up disk space and retry.", "win32"},
+	193:  {"ERROR_BAD_EXE_FORMAT", "The file is not a valid Win32 application.", "The installer may be corrupted or built for another architecture.", "win32"},
+	259:  {"ERROR_NO_MORE_ITEMS", "No more data is available.", "pnputil returns 259 when no device was updated by the driver package.", "win32"},
+	740:  {"ERROR_ELEVATION_REQUIRED", "The requested operation requires elevation.", "Run the app as administrator.", "win32"},
+	1223: {"ERROR_CANCELLED", "The operation was canceled by the user.", "", "win32"},
+	1460: {"ERROR_TIMEOUT", "This operation returned because the timeout period expired.", "", "win32"},
+
+	// Windows Installer
+	1601: {"ERROR_INSTALL_SERVICE_FAILURE", "The Windows Installer service could not be accessed.", "Check that the Windows Installer service is running, or start Windows in normal mode.", "msi"},
+	1602: {"ERROR_INSTALL_USEREXIT", "User cancelled installation.", "", "msi"},
+	1603: {"ERROR_INSTALL_FAILURE", "Fatal error during installation.", "Run the installer with /l*v <log> and search the log for \"return value 3\".", "msi"},
+	1604: {"ERROR_INSTALL_SUSPEND", "Installation suspended, incomplete.", "", "msi"},
+	1605: {"ERROR_UNKNOWN_PRODUCT", "This action is only valid for products that are currently installed.", "", "msi"},
+	1612: {"ERROR_INSTALL_SOURCE_ABSENT", "The installation source for this product is not available.", "Check that the original installer is still accessible.", "msi"},
+	1618: {"ERROR_INSTALL_ALREADY_RUNNING", "Another installation is already in progress.", "Wait for the other installation or Windows Update to finish, or reboot. Drivers holding the msi lock never run at the same time.", "msi"},
+	1619: {"ERROR_INSTALL_PACKAGE_OPEN_FAILED", "This installation package could not be opened.", "Check that the package exists and is accessible.", "msi"},
+	1620: {"ERROR_INSTALL_PACKAGE_INVALID", "This installation package could not be opened. It may not be a valid Windows Installer package.", "The package may be corrupted; download it again.", "msi"},
+	1622: {"ERROR_INSTALL_LOG_FAILURE", "Error opening installation log file.", "Check that the log path exists and is writable.", "msi"},
+	1624: {"ERROR_INSTALL_TRANSFORM_FAILURE", "Error applying transforms.", "Check the paths of the transforms in TRANSFORMS.", "msi"},
+	1625: {"ERROR_INSTALL_PACKAGE_REJECTED", "This installation is forbidden by system policy.", "", "msi"},
+	1633: {"ERROR_INSTALL_PLATFORM_UNSUPPORTED", "This installation package is not supported by this processor type.", "Use the package for the architecture of the machine.", "msi"},
+	1638: {"ERROR_PRODUCT_VERSION", "Another version of this product is already installed.", "Uninstall the installed version first.", "msi"},
+	1639: {"ERROR_INVALID_COMMAND_LINE", "Invalid command line argument.", "Check the flags of the driver.", "msi"},
+	1641: {"ERROR_SUCCESS_REBOOT_INITIATED", "The installer has initiated a restart.", "", "msi"},
+	1642: {"ERROR_PATCH_TARGET_NOT_FOUND", "The upgrade cannot be installed because the program to be upgraded may be missing.", "", "msi"},
+	1643: {"ERROR_PATCH_PACKAGE_REJECTED", "The patch package is not permitted by software restriction policy.", "", "msi"},
+	1644: {"ERROR_INSTALL_TRANSFORM_REJECTED", "One or more customizations are not permitted by software restriction policy.", "", "msi"},
+	3010: {"ERROR_SUCCESS_REBOOT_REQUIRED", "A restart is required to complete the install.", "", "msi"},
+
+	// HRESULTs
+	0x00240006: {"WU_S_ALREADY_INSTALLED", "The update is already installed.", "", "hresult"},
+	0x800F081F: {"CBS_E_SOURCE_MISSING", "The source files could not be found.", "", "hresult"},
+	0x800F0922: {"CBS_E_INSTALLERS_FAILED", "Processing advanced installers and generic commands failed.", "Check the free space of the system reserved partition and the network connection.", "hresult"},
+	0x80240017: {"WU_E_NOT_APPLICABLE", "The update is not applicable to this computer.", "The update may be superseded, or meant for another edition or architecture.", "hresult"},
+
+	// NTSTATUS of crashed processes
+	0xC0000005: {"STATUS_ACCESS_VIOLATION", "The installer crashed with an access violation.", "", "ntstatus"},
+	0xC000013A: {"STATUS_CONTROL_C_EXIT", "The installer was terminated with Ctrl+C.", "", "ntstatus"},
+	0xC0000135: {"STATUS_DLL_NOT_FOUND", "A DLL required by the installer was not found.", "Install the runtime the installer depends on, e.g. the Visual C++ Redistributable.", "ntstatus"},
+	0xC0000142: {"STATUS_DLL_INIT_FAILED", "A DLL failed to initialize.", "", "ntstatus"},
+
+	// InstallShield silent mode, which returns negative codes
+	0xFFFFFFFF: {"", "InstallShield: general error.", "Run the installer with /f2<log> to find the cause.", "installshield"},
+	0xFFFFFFFE: {"", "InstallShield: invalid mode.", "", "installshield"},
+	0xFFFFFFFD: {"", "InstallShield: required data not found in the Setup.iss response file.", "Record the response file again with /r for this version of the installer.", "installshield"},
+	0xFFFFFFFC: {"", "InstallShield: not enough memory available.", "", "installshield"},
+	0xFFFFFFFB: {"", "InstallShield: file does not exist.", "", "installshield"},
+	0xFFFFFFFA: {"", "InstallShield: cannot write to the response file.", "", "installshield"},
+	0xFFFFFFF9: {"", "InstallShield: unable to write to the log file.", "Check that the /f2 log path is writable.", "installshield"},
+	0xFFFFFFF8: {"", "InstallShield: invalid path to the response file.", "Check the /f1 path; it must be absolute.", "installshield"},
+	0xFFFFFFF5: {"", "InstallShield: unknown error during setup.", "", "installshield"},
+	0xFFFFFFF4: {"", "InstallShield: dialog boxes are out of order.", "Record the response file again with /r for this version of the installer.", "installshield"},
+	0xFFFFFFCD: {"", "InstallShield: cannot create the specified folder.", "", "installshield"},
+	0xFFFFFFCC: {"", "InstallShield: cannot access the specified file or folder.", "", "installshield"},
+	0xFFFFFFCB: {"", "InstallShield: invalid option selected.", "", "installshield"},
+}
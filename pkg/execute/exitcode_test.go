@@ -0,0 +1,52 @@
+package execute_test
+
+import (
+	"testing"
+
+	"install-it/pkg/execute"
+	"install-it/pkg/storage"
+)
+
+func TestDescribeExitCode(t *testing.T) {
+	t.Parallel()
+
+	driver := storage.Driver{ExitCodeNotes: []storage.ExitCodeNote{
+		{Code: 2, Description: "No supported device found", Hint: "Check the model"},
+		{Code: -2147024891, Description: "Vendor access denied"},
+	}}
+
+	tests := []struct {
+		name   string
+		driver storage.Driver
+		code   int
+		want   string // Name, or Description for driver notes
+		source string
+	}{
+		{"msi", storage.Driver{}, 1603, "ERROR_INSTALL_FAILURE", "msi"},
+		{"win32", storage.Driver{}, 5, "ERROR_ACCESS_DENIED", "win32"},
+		{"hresult", storage.Driver{}, 0x80240017, "WU_E_NOT_APPLICABLE", "hresult"},
+		{"wrapped win32", storage.Driver{}, 0x80070005, "HRESULT_FROM_WIN32(ERROR_ACCESS_DENIED)", "hresult"},
+		{"wrapped msi", storage.Driver{}, 0x80070643, "HRESULT_FROM_WIN32(ERROR_INSTALL_FAILURE)", "hresult"},
+		{"ntstatus", storage.Driver{}, 0xC0000005, "STATUS_ACCESS_VIOLATION", "ntstatus"},
+		{"installshield", storage.Driver{}, 0xFFFFFFFD, "", "installshield"},
+		{"driver note", driver, 2, "No supported device found", "driver"},
+		{"signed driver note", driver, 0x80070005, "Vendor access denied", "driver"},
+		{"unknown", storage.Driver{}, 424242, "", ""},
+		{"not exited", storage.Driver{}, -1, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := execute.DescribeExitCode(tt.driver, tt.code)
+			got := info.Name
+			if tt.source == "driver" {
+				got = info.Description
+			}
+			if got != tt.want || info.Source != tt.source {
+				t.Errorf("got %+v, want %q from %q", info, tt.want, tt.source)
+			}
+			if tt.source != "" && info.Description == "" {
+				t.Error("known codes should have a description")
+			}
+		})
+	}
+}
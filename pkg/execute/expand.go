@@ -0,0 +1,96 @@
+package execute
+
+import (
+	"install-it/pkg/storage"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Expander expands placeholders in the Path, Flags, WorkDir, Env and verification
+// check of drivers, so that paths survive moving the media. The placeholders are:
+//
+//	{root}          directory of the app
+//	{drivers}       directory of the driver files
+//	{driverDir}     directory of the driver's expanded Path
+//	{arch}          architecture of the OS: x86, x64 or arm64
+//	{date}          current date as YYYY-MM-DD
+//	{computerName}  name of the machine
+//	{logDir}        directory of the install logs
+//
+// Unknown placeholders are kept verbatim, since flags such as MSI product codes
+// are written in braces too. A nil Expander leaves drivers unchanged.
+type Expander struct {
+	Root    string
+	Drivers string
+	LogDir  string
+	Now     func() time.Time // Clock for {date}, time.Now when nil
+}
+
+// Expand returns a copy of driver with its placeholders expanded.
+func (e *Expander) Expand(driver storage.Driver) storage.Driver {
+	if e == nil {
+		return driver
+	}
+
+	vars := e.vars()
+	driver.Path = strings.NewReplacer(vars...).Replace(driver.Path)
+
+	driverDir := filepath.Dir(driver.Path)
+	if !filepath.IsAbs(driverDir) && e.Root != "" {
+		driverDir = filepath.Join(e.Root, driverDir)
+	}
+	replacer := strings.NewReplacer(append(vars, "{driverDir}", driverDir)...)
+
+	driver.Flags = expandAll(replacer, driver.Flags)
+	driver.Env = expandAll(replacer, driver.Env)
+	driver.WorkDir = replacer.Replace(driver.WorkDir)
+	driver.Verify.Path = replacer.Replace(driver.Verify.Path)
+	driver.Verify.Flags = expandAll(replacer, driver.Verify.Flags)
+	return driver
+}
+
+// vars returns the placeholders other than {driverDir} with their values, as
+// pairs for strings.NewReplacer.
+func (e *Expander) vars() []string {
+	now := time.Now
+	if e.Now != nil {
+		now = e.Now
+	}
+	computerName, _ := os.Hostname()
+
+	return []string{
+		"{root}", e.Root,
+		"{drivers}", e.Drivers,
+		"{arch}", arch(),
+		"{date}", now().Format("2006-01-02"),
+		"{computerName}", computerName,
+		"{logDir}", e.LogDir,
+	}
+}
+
+func expandAll(replacer *strings.Replacer, values []string) []string {
+	if values == nil {
+		return nil
+	}
+	expanded := make([]string, len(values))
+	for i, v := range values {
+		expanded[i] = replacer.Replace(v)
+	}
+	return expanded
+}
+
+// arch returns the architecture of the OS in the naming used by driver
+// packages. A 32-bit build running on a 64-bit Windows reports x64.
+func arch() string {
+	switch {
+	case runtime.GOARCH == "arm64":
+		return "arm64"
+	case runtime.GOARCH == "amd64", os.Getenv("PROCESSOR_ARCHITEW6432") != "":
+		return "x64"
+	default:
+		return "x86"
+	}
+}
@@ -0,0 +1,83 @@
+package execute_test
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"install-it/pkg/execute"
+	"install-it/pkg/storage"
+)
+
+func TestExpander_Expand(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	e := &execute.Expander{
+		Root:    root,
+		Drivers: filepath.Join(root, "drivers"),
+		LogDir:  filepath.Join(root, "conf", "logs"),
+		Now:     func() time.Time { return time.Date(2026, 10, 17, 8, 0, 0, 0, time.UTC) },
+	}
+	driver := storage.Driver{
+		Path:    "{drivers}/network/setup.exe",
+		Flags:   []string{"/log", "{logDir}/{date}.log", "/dir={driverDir}", "{8E1C6F1A-0000}"},
+		WorkDir: "{driverDir}",
+		Env:     []string{"ARCH={arch}"},
+		Verify:  storage.VerifyCheck{Type: storage.VerifyCommand, Path: "{driverDir}/check.exe", Flags: []string{"/log={logDir}"}},
+	}
+
+	got := e.Expand(driver)
+
+	driverDir := filepath.Join(root, "drivers", "network")
+	if got.Path != filepath.Join(root, "drivers")+"/network/setup.exe" {
+		t.Errorf("path: got %q", got.Path)
+	}
+	wantFlags := []string{"/log", filepath.Join(root, "conf", "logs") + "/2026-10-17.log", "/dir=" + driverDir, "{8E1C6F1A-0000}"}
+	if !slices.Equal(got.Flags, wantFlags) {
+		t.Errorf("flags: got %q, want %q", got.Flags, wantFlags)
+	}
+	if got.WorkDir != driverDir {
+		t.Errorf("work dir: got %q, want %q", got.WorkDir, driverDir)
+	}
+	if !slices.Contains([]string{"ARCH=x86", "ARCH=x64", "ARCH=arm64"}, got.Env[0]) {
+		t.Errorf("env: got %q", got.Env)
+	}
+	if got.Verify.Path != driverDir+"/check.exe" || got.Verify.Flags[0] != "/log="+filepath.Join(root, "conf", "logs") {
+		t.Errorf("verification check: got %+v", got.Verify)
+	}
+	if driver.Flags[1] != "{logDir}/{date}.log" {
+		t.Error("Expand should not modify the original driver")
+	}
+}
+
+func TestExpander_DriverDirOfRelativePath(t *testing.T) {
+	t.Parallel()
+
+	e := &execute.Expander{Root: "root"}
+	got := e.Expand(storage.Driver{Path: "drivers/setup.exe", WorkDir: "{driverDir}"})
+	if want := filepath.Join("root", "drivers"); got.WorkDir != want {
+		t.Errorf("work dir: got %q, want %q", got.WorkDir, want)
+	}
+}
+
+func TestExpander_Nil(t *testing.T) {
+	t.Parallel()
+
+	var e *execute.Expander
+	driver := storage.Driver{Path: "{root}/setup.exe", Flags: []string{"{date}"}}
+	if got := e.Expand(driver); got.Path != driver.Path || got.Flags[0] != "{date}" {
+		t.Errorf("nil expander should leave the driver unchanged, got %+v", got)
+	}
+}
+
+func TestCommandExecutor_Preview(t *testing.T) {
+	t.Parallel()
+
+	ce := execute.CommandExecutor{Expander: &execute.Expander{Root: "root"}}
+	got := ce.Preview(storage.Driver{Path: "{root}/setup.exe", Flags: []string{"/s", "/log {root}/a b.log"}})
+	if want := `root/setup.exe /s "/log root/a b.log"`; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -15,6 +15,7 @@ import (
 func FuzzCommandResult_JSONRoundtrip(f *testing.F) {
 	// Seed corpus
 	f.Add(`{"lapse":1.5,"exitCode":0,"stdout":"hello","stderr":"","error":"","aborted":false}`)
+	f.Add(`{"lapse":1.5,"exitCode":1,"stdout":"","stderr":"","error":"","aborted":false,"status":"failed"}`)
 	f.Add(`{"lapse":-1,"exitCode":1,"stdout":"","stderr":"error output","error":"program not found","aborted":true}`)
 	f.Add(`{}`)
 	f.Add(`{"lapse":0,"exitCode":0,"stdout":"","stderr":"","error":"","aborted":false}`)
@@ -58,6 +59,9 @@ func FuzzCommandResult_JSONRoundtrip(f *testing.F) {
 		if cr.Aborted != cr2.Aborted {
 			t.Errorf("Aborted: %v → %v", cr.Aborted, cr2.Aborted)
 		}
+		if cr.Status != cr2.Status {
+			t.Errorf("Status: %q → %q", cr.Status, cr2.Status)
+		}
 		// Lapse is float32; re-encoding via float64 JSON intermediary may cause
 		// tiny precision differences, so we accept a small tolerance.
 		diff := cr.Lapse - cr2.Lapse
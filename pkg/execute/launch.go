@@ -0,0 +1,108 @@
+package execute
+
+import (
+	"install-it/pkg/storage"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// LaunchFunc returns the program and arguments that install the file at path,
+// passing on the flags of the driver.
+type LaunchFunc func(path string, flags []string) (program string, args []string)
+
+// Launchers builds the invocation of installers that cannot be executed
+// directly, by the extension of the driver's Path. The built-in types are:
+//
+//	.msi        msiexec /i <path> <flags>, with /qn /norestart when no flags are set
+//	.msu        wusa <path> <flags>, with /quiet /norestart when no flags are set
+//	.inf        pnputil /add-driver <path> /install <flags>
+//	.ps1        powershell -NoProfile -ExecutionPolicy Bypass -File <path> <flags>
+//	.bat, .cmd  cmd /d /s /c "<path> <flags>"
+//	.reg        reg import <path> <flags>
+//
+// A nil Launchers launches the built-in types.
+type Launchers struct {
+	mu    sync.RWMutex
+	byExt map[string]LaunchFunc
+}
+
+// NewLaunchers creates a registry with the built-in types.
+func NewLaunchers() *Launchers {
+	l := &Launchers{byExt: make(map[string]LaunchFunc)}
+	l.Register(".msi", func(path string, flags []string) (string, []string) {
+		return "msiexec", append([]string{"/i", path}, orDefault(flags, "/qn", "/norestart")...)
+	})
+	l.Register(".msu", func(path string, flags []string) (string, []string) {
+		return "wusa", append([]string{path}, orDefault(flags, "/quiet", "/norestart")...)
+	})
+	l.Register(".inf", func(path string, flags []string) (string, []string) {
+		return "pnputil", append([]string{"/add-driver", path, "/install"}, flags...)
+	})
+	l.Register(".ps1", func(path string, flags []string) (string, []string) {
+		return "powershell", append([]string{"-NoProfile", "-ExecutionPolicy", "Bypass", "-File", path}, flags...)
+	})
+	batch := func(path string, flags []string) (string, []string) {
+		return "cmd", append([]string{"/d", "/s", "/c", path}, flags...)
+	}
+	l.Register(".bat", batch)
+	l.Register(".cmd", batch)
+	l.Register(".reg", func(path string, flags []string) (string, []string) {
+		return "reg", append([]string{"import", path}, flags...)
+	})
+	return l
+}
+
+// builtinLaunchers is used by a nil Launchers.
+var builtinLaunchers = NewLaunchers()
+
+// Register sets the launcher of files with the extension ext, such as ".appx",
+// replacing any launcher of ext. A nil launch makes the files run directly.
+func (l *Launchers) Register(ext string, launch LaunchFunc) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	ext = strings.ToLower(ext)
+	if launch == nil {
+		delete(l.byExt, ext)
+	} else {
+		l.byExt[ext] = launch
+	}
+}
+
+// Launch returns a copy of driver that runs its Path through the launcher of
+// its extension, or driver unchanged if there is none.
+func (l *Launchers) Launch(driver storage.Driver) storage.Driver {
+	if l == nil {
+		l = builtinLaunchers
+	}
+
+	l.mu.RLock()
+	launch := l.byExt[strings.ToLower(filepath.Ext(driver.Path))]
+	l.mu.RUnlock()
+
+	if launch != nil {
+		driver.Path, driver.Flags = launch(driver.Path, slices.Clone(driver.Flags))
+	}
+	return driver
+}
+
+// launched reports whether driver runs through a launcher, so that its Path is
+// an installer file rather than a program.
+func (l *Launchers) launched(driver storage.Driver) bool {
+	if l == nil {
+		l = builtinLaunchers
+	}
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.byExt[strings.ToLower(filepath.Ext(driver.Path))] != nil
+}
+
+func orDefault(flags []string, defaults ...string) []string {
+	if len(flags) == 0 {
+		return defaults
+	}
+	return flags
+}
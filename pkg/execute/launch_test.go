@@ -0,0 +1,77 @@
+package execute_test
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"install-it/pkg/execute"
+	"install-it/pkg/storage"
+)
+
+func TestLaunchers_Launch(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		path    string
+		flags   []string
+		program string
+		args    []string
+	}{
+		{`D:\lan\LAN.MSI`, nil, "msiexec", []string{"/i", `D:\lan\LAN.MSI`, "/qn", "/norestart"}},
+		{"lan.msi", []string{"/passive", "ADDLOCAL=ALL"}, "msiexec", []string{"/i", "lan.msi", "/passive", "ADDLOCAL=ALL"}},
+		{"kb5034441.msu", nil, "wusa", []string{"kb5034441.msu", "/quiet", "/norestart"}},
+		{"iaStorAC.inf", []string{"/subdirs"}, "pnputil", []string{"/add-driver", "iaStorAC.inf", "/install", "/subdirs"}},
+		{"tweak.ps1", []string{"-Silent"}, "powershell", []string{"-NoProfile", "-ExecutionPolicy", "Bypass", "-File", "tweak.ps1", "-Silent"}},
+		{"install.bat", nil, "cmd", []string{"/d", "/s", "/c", "install.bat"}},
+		{"install.cmd", []string{"/q"}, "cmd", []string{"/d", "/s", "/c", "install.cmd", "/q"}},
+		{"power.reg", nil, "reg", []string{"import", "power.reg"}},
+		{"setup.exe", []string{"/s"}, "setup.exe", []string{"/s"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			var launchers *execute.Launchers
+			got := launchers.Launch(storage.Driver{Path: tt.path, Flags: tt.flags, AllowRtCodes: []int32{0}})
+			if got.Path != tt.program || !slices.Equal(got.Flags, tt.args) {
+				t.Errorf("got %s %v, want %s %v", got.Path, got.Flags, tt.program, tt.args)
+			}
+			if !slices.Equal(got.AllowRtCodes, []int32{0}) {
+				t.Errorf("outcome rules changed: %v", got.AllowRtCodes)
+			}
+		})
+	}
+}
+
+func TestLaunchers_Register(t *testing.T) {
+	t.Parallel()
+
+	launchers := execute.NewLaunchers()
+	launchers.Register(".APPX", func(path string, flags []string) (string, []string) {
+		return "powershell", append([]string{"Add-AppxPackage", path}, flags...)
+	})
+	launchers.Register(".bat", nil)
+
+	if got := launchers.Launch(storage.Driver{Path: "app.appx"}); got.Path != "powershell" || !slices.Equal(got.Flags, []string{"Add-AppxPackage", "app.appx"}) {
+		t.Errorf("registered type: got %s %v", got.Path, got.Flags)
+	}
+	if got := launchers.Launch(storage.Driver{Path: "install.bat"}); got.Path != "install.bat" {
+		t.Errorf("unregistered type should run directly, got %s", got.Path)
+	}
+	if got := execute.NewLaunchers().Launch(storage.Driver{Path: "install.bat"}); got.Path != "cmd" {
+		t.Errorf("registries should not share types, got %s", got.Path)
+	}
+}
+
+func TestCommandExecutor_Preview_Launches(t *testing.T) {
+	t.Parallel()
+
+	ce := &execute.CommandExecutor{}
+	if got := ce.Preview(storage.Driver{Path: "drivers/lan.msi", Flags: []string{"/qb"}}); got != "msiexec /i drivers/lan.msi /qb" {
+		t.Errorf("got %q", got)
+	}
+	// Installers in archives are launched by the type of their entry
+	want := "msiexec /i " + filepath.Join("drivers", "lan.zip", "x64", "lan.msi") + " /qn /norestart"
+	if got := ce.Preview(storage.Driver{Path: "drivers/lan.zip", ArchiveEntry: `x64\lan.msi`}); got != want {
+		t.Errorf("archive: got %q, want %q", got, want)
+	}
+}
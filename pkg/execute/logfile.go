@@ -0,0 +1,173 @@
+package execute
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// outputTailLimit is the number of bytes of stdout and stderr kept in memory
+// for CommandResult. The full output goes to the log file of the command.
+const outputTailLimit = 1 << 20
+
+// Page sizes of LogStore.Read.
+const (
+	defaultLogPageSize = 64 * 1024
+	maxLogPageSize     = 1 << 20
+)
+
+// LogStore keeps the full decoded output of commands in files under Dir, at
+// <run>/<name>.log. Results refer to their file by the slash-separated path
+// relative to Dir. A nil LogStore keeps nothing.
+type LogStore struct {
+	Dir string
+}
+
+// LogPage is a part of a log file returned by LogStore.Read.
+type LogPage struct {
+	Text   string `json:"text"`
+	Offset int64  `json:"offset"` // Byte offset of Text in the file
+	Next   int64  `json:"next"`   // Offset of the next page
+	Size   int64  `json:"size"`   // Size of the file at the time of reading
+	Eof    bool   `json:"eof"`    // Text reaches the end of the file
+}
+
+// Read returns up to limit bytes of the log file ref starting at the byte
+// offset, ending at a line break unless the page holds a single long line. A
+// limit of 0 or less reads a default page size. The file of a running command
+// can be read while it grows.
+func (s *LogStore) Read(ref string, offset int64, limit int) (LogPage, error) {
+	file, err := os.Open(s.path(ref))
+	if err != nil {
+		return LogPage{}, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return LogPage{}, err
+	}
+	if limit <= 0 {
+		limit = defaultLogPageSize
+	}
+	limit = min(limit, maxLogPageSize)
+	offset = min(max(offset, 0), info.Size())
+
+	buf := make([]byte, min(int64(limit), info.Size()-offset))
+	n, err := file.ReadAt(buf, offset)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return LogPage{}, err
+	}
+	buf = buf[:n]
+
+	eof := offset+int64(n) >= info.Size()
+	if i := bytes.LastIndexByte(buf, '\n'); !eof && i != -1 {
+		buf = buf[:i+1]
+	}
+	return LogPage{
+		Text:   string(buf),
+		Offset: offset,
+		Next:   offset + int64(len(buf)),
+		Size:   info.Size(),
+		Eof:    eof,
+	}, nil
+}
+
+// create creates the log file name of run, and returns it with its reference.
+// It returns a nil file if s is nil.
+func (s *LogStore) create(run, name string) (*os.File, string, error) {
+	if s == nil {
+		return nil, "", nil
+	}
+
+	ref := path.Join(logFileName(run), logFileName(name)+".log")
+	if err := os.MkdirAll(filepath.Dir(s.path(ref)), os.ModePerm); err != nil {
+		return nil, "", err
+	}
+	file, err := os.Create(s.path(ref))
+	if err != nil {
+		return nil, "", err
+	}
+	return file, ref, nil
+}
+
+// capture makes output write its following lines to the new log file name of
+// run as well, and returns the reference of the file with a function to call
+// once the command has finished. Nothing is kept if s is nil.
+func (s *LogStore) capture(output *OutputLog, run, name string) (string, func() error, error) {
+	file, ref, err := s.create(run, name)
+	if err != nil || file == nil {
+		return "", func() error { return nil }, err
+	}
+	output.setFile(file)
+	return ref, func() error { return errors.Join(output.setFile(nil), file.Close()) }, nil
+}
+
+// path returns the file of ref, which is kept within Dir.
+func (s *LogStore) path(ref string) string {
+	return filepath.Join(s.Dir, filepath.FromSlash(path.Clean("/"+ref)))
+}
+
+// logFileName replaces the characters that are not allowed in file names on
+// Windows.
+func logFileName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if r < ' ' || strings.ContainsRune(`<>:"/\|?*`, r) {
+			return '_'
+		}
+		return r
+	}, strings.TrimSpace(name))
+	if name == "" {
+		return "_"
+	}
+	return name
+}
+
+// tailBuffer is a ring buffer keeping the last limit bytes written to it.
+type tailBuffer struct {
+	limit   int
+	buf     []byte // Grows up to limit, then is overwritten from pos
+	pos     int
+	written int64
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	n := len(p)
+	b.written += int64(n)
+	if b.limit <= 0 {
+		return n, nil
+	}
+
+	if room := b.limit - len(b.buf); room > 0 {
+		if len(p) <= room {
+			b.buf = append(b.buf, p...)
+			return n, nil
+		}
+		b.buf, p = append(b.buf, p[:room]...), p[room:]
+	}
+	if len(p) > b.limit {
+		p = p[len(p)-b.limit:]
+	}
+	copied := copy(b.buf[b.pos:], p)
+	copy(b.buf, p[copied:])
+	b.pos = (b.pos + len(p)) % b.limit
+	return n, nil
+}
+
+// Bytes returns the kept bytes in the order they were written.
+func (b *tailBuffer) Bytes() []byte {
+	return append(append([]byte{}, b.buf[b.pos:]...), b.buf[:b.pos]...)
+}
+
+func (b *tailBuffer) String() string {
+	return string(b.Bytes())
+}
+
+// truncated reports whether earlier bytes were dropped.
+func (b *tailBuffer) truncated() bool {
+	return b.written > int64(len(b.buf))
+}
@@ -0,0 +1,102 @@
+package execute
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTailBuffer(t *testing.T) {
+	tests := []struct {
+		name      string
+		writes    []string
+		want      string
+		truncated bool
+	}{
+		{"below limit", []string{"ab", "cd"}, "abcd", false},
+		{"at limit", []string{"abcde", "f"}, "abcdef", false},
+		{"wraps", []string{"abcd", "efgh"}, "cdefgh", true},
+		{"wraps twice", []string{"abcd", "efgh", "ijk", "l"}, "ghijkl", true},
+		{"write over limit", []string{"ab", "cdefghijk"}, "fghijk", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &tailBuffer{limit: 6}
+			for _, w := range tt.writes {
+				if n, _ := b.Write([]byte(w)); n != len(w) {
+					t.Fatalf("Write(%q): got %d, want %d", w, n, len(w))
+				}
+			}
+			if got := b.String(); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+			if b.truncated() != tt.truncated {
+				t.Errorf("truncated: got %v, want %v", b.truncated(), tt.truncated)
+			}
+		})
+	}
+}
+
+func TestLogStore_CaptureAndRead(t *testing.T) {
+	store := &LogStore{Dir: t.TempDir()}
+	output := &OutputLog{}
+
+	ref, closeLog, err := store.capture(output, "20261017-120000", `30_Intel: LAN/WiFi`)
+	if err != nil {
+		t.Fatalf("capture: %v", err)
+	}
+	if ref != "20261017-120000/30_Intel_ LAN_WiFi.log" {
+		t.Errorf("unexpected reference %q", ref)
+	}
+	w := &lineWriter{log: output, stream: "stdout"}
+	w.Write([]byte("first\nsecond\nthird"))
+	w.flush()
+	if err := closeLog(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	// Lines after closing are no longer written to the file
+	output.append(OutputLine{Stream: "stdout", Text: "late"})
+
+	page, err := store.Read(ref, 0, 10)
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if page.Text != "first\n" || page.Next != 6 || page.Size != 19 || page.Eof {
+		t.Errorf("first page: got %+v", page)
+	}
+
+	page, err = store.Read(ref, page.Next, 0)
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if page.Text != "second\nthird\n" || page.Next != page.Size || !page.Eof {
+		t.Errorf("last page: got %+v", page)
+	}
+
+	// A page holding part of a long line is not cut
+	page, _ = store.Read(ref, 0, 3)
+	if page.Text != "fir" || page.Next != 3 {
+		t.Errorf("partial line: got %+v", page)
+	}
+}
+
+func TestLogStore_ReadStaysInDir(t *testing.T) {
+	dir := t.TempDir()
+	store := &LogStore{Dir: filepath.Join(dir, "logs")}
+	if err := os.WriteFile(filepath.Join(dir, "secret.txt"), []byte("secret"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if page, err := store.Read("../secret.txt", 0, 0); err == nil || strings.Contains(page.Text, "secret") {
+		t.Errorf("read outside of Dir: %+v, %v", page, err)
+	}
+}
+
+func TestLogStore_NilKeepsNothing(t *testing.T) {
+	var store *LogStore
+	ref, closeLog, err := store.capture(&OutputLog{}, "run", "name")
+	if ref != "" || err != nil || closeLog() != nil {
+		t.Errorf("got %q, %v", ref, err)
+	}
+}
@@ -0,0 +1,7 @@
+//go:build !windows
+
+package execute
+
+func oemCodePage() uint32 {
+	return 0
+}
@@ -0,0 +1,12 @@
+//go:build windows
+
+package execute
+
+import "syscall"
+
+var procGetOEMCP = syscall.NewLazyDLL("kernel32.dll").NewProc("GetOEMCP")
+
+func oemCodePage() uint32 {
+	cp, _, _ := procGetOEMCP.Call()
+	return uint32(cp)
+}
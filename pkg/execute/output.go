@@ -0,0 +1,273 @@
+package execute
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/saintfish/chardet"
+	"golang.org/x/net/html/charset"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/korean"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// maxLineLength is the size at which an unterminated line is emitted anyway,
+// so that output without newlines still shows up while the command runs.
+const maxLineLength = 64 * 1024
+
+// outputLogLimit is the number of bytes of decoded lines an OutputLog keeps in
+// memory. The full output goes to the log file of the command.
+const outputLogLimit = 1 << 20
+
+// OutputLine is a single decoded line written by a command.
+type OutputLine struct {
+	Stream string `json:"stream"` // "stdout" or "stderr"
+	Text   string `json:"text"`
+	Line   int    `json:"line"` // Number of the line in the whole output, from 0
+}
+
+// OutputLog collects the decoded output lines of a command, in the order they
+// were written. Only the last lines are kept, up to outputLogLimit bytes.
+type OutputLog struct {
+	mu        sync.Mutex
+	lines     []OutputLine // Last lines written
+	size      int          // Bytes of text in lines
+	dropped   int          // Lines dropped before lines[0]
+	limit     int          // Bytes of text kept, outputLogLimit when 0
+	lastWrite time.Time
+	file      io.Writer // Receives every line as well, when set
+	fileErr   error     // First error of writing to file
+}
+
+// Lines returns the lines written since offset, so that a poller can pass the
+// number of lines received so far to get only the new ones. Lines no longer
+// kept in memory are skipped; the Line of the last line returned tells the
+// offset to continue from.
+func (o *OutputLog) Lines(offset int) []OutputLine {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if offset < 0 || offset >= o.dropped+len(o.lines) {
+		return []OutputLine{}
+	}
+	return append([]OutputLine{}, o.lines[max(offset-o.dropped, 0):]...)
+}
+
+// tail returns the text of the last lines, one per line, up to limit bytes.
+func (o *OutputLog) tail(limit int) string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	start, size := len(o.lines), 0
+	for start > 0 && size < limit {
+		start--
+		size += len(o.lines[start].Text) + 1
+	}
+
+	var b strings.Builder
+	b.Grow(size)
+	for _, line := range o.lines[start:] {
+		b.WriteString(line.Text)
+		b.WriteByte('\n')
+	}
+	tail := b.String()
+	if len(tail) > limit {
+		tail = strings.ToValidUTF8(tail[len(tail)-limit:], "")
+	}
+	return tail
+}
+
+// idleFor returns how long nothing was written to the log, counting from since
+// if nothing was written at all.
+func (o *OutputLog) idleFor(since time.Time) time.Duration {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.lastWrite.After(since) {
+		since = o.lastWrite
+	}
+	return time.Since(since)
+}
+
+func (o *OutputLog) touch() {
+	o.mu.Lock()
+	o.lastWrite = time.Now()
+	o.mu.Unlock()
+}
+
+func (o *OutputLog) append(line OutputLine) {
+	o.mu.Lock()
+	line.Line = o.dropped + len(o.lines)
+	o.lines = append(o.lines, line)
+	o.size += len(line.Text)
+
+	limit := o.limit
+	if limit <= 0 {
+		limit = outputLogLimit
+	}
+	// The last line is kept whatever its size
+	n := 0
+	for o.size > limit && n < len(o.lines)-1 {
+		o.size -= len(o.lines[n].Text)
+		n++
+	}
+	if n > 0 {
+		clear(o.lines[:n])
+		o.lines, o.dropped = o.lines[n:], o.dropped+n
+	}
+
+	if o.file != nil && o.fileErr == nil {
+		_, o.fileErr = io.WriteString(o.file, line.Text+"\n")
+	}
+	o.mu.Unlock()
+}
+
+// setFile makes the log write every following line to file as well, or stop
+// doing so if file is nil. It returns the first error of writing to the
+// previous file.
+func (o *OutputLog) setFile(file io.Writer) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	err := o.fileErr
+	o.file, o.fileErr = file, nil
+	return err
+}
+
+// lineWriter splits a pipe into lines and decodes each line on its own before
+// appending it to the log.
+type lineWriter struct {
+	log    *OutputLog
+	stream string
+	match  *outputMatch // Matches the output patterns of the driver, if any
+	buf    []byte
+	utf16  bool // Output is UTF-16LE, detected on the first write
+	probed bool
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.log.touch()
+	if !w.probed && len(p) > 0 {
+		w.probed = true
+		w.utf16 = bytes.HasPrefix(p, []byte{0xFF, 0xFE}) || len(p) >= 2 && p[0] != 0 && p[1] == 0
+	}
+
+	w.buf = append(w.buf, p...)
+	for {
+		end, next := w.lineEnd()
+		if end == -1 {
+			if len(w.buf) < maxLineLength {
+				break
+			}
+			end, next = len(w.buf), len(w.buf)
+		}
+		w.emit(w.buf[:end])
+		w.buf = w.buf[next:]
+	}
+	return len(p), nil
+}
+
+// lineEnd returns the end of the first complete line in the buffer and the
+// start of the line after it, or -1 when no line is complete yet.
+func (w *lineWriter) lineEnd() (int, int) {
+	if !w.utf16 {
+		if i := bytes.IndexByte(w.buf, '\n'); i != -1 {
+			return i, i + 1
+		}
+		return -1, -1
+	}
+
+	for i := 0; i+1 < len(w.buf); i += 2 {
+		if w.buf[i] == '\n' && w.buf[i+1] == 0 {
+			return i, i + 2
+		}
+	}
+	return -1, -1
+}
+
+// flush emits the remaining unterminated line, if any.
+func (w *lineWriter) flush() {
+	if len(w.buf) > 0 {
+		w.emit(w.buf)
+		w.buf = nil
+	}
+}
+
+func (w *lineWriter) emit(line []byte) {
+	text := strings.TrimRight(decodeLine(line, w.utf16), "\r")
+	w.match.line(text)
+	w.log.append(OutputLine{Stream: w.stream, Text: text})
+}
+
+// decodeLine converts a raw line to UTF-8. Non UTF-8 lines are decoded with
+// the OEM codepage when the system has one, or by chardet detection otherwise.
+func decodeLine(line []byte, utf16 bool) string {
+	if utf16 {
+		if s, err := unicode.UTF16(unicode.LittleEndian, unicode.UseBOM).NewDecoder().Bytes(line); err == nil {
+			return strings.TrimPrefix(string(s), "\ufeff")
+		}
+	}
+
+	if utf8.Valid(line) {
+		return string(line)
+	}
+
+	if enc := oemEncoding(); enc != nil {
+		if s, err := enc.NewDecoder().Bytes(line); err == nil {
+			return string(s)
+		}
+	}
+
+	if result, err := chardet.NewTextDetector().DetectBest(line); err == nil {
+		if enc, _ := charset.Lookup(result.Charset); enc != nil {
+			if s, err := enc.NewDecoder().Bytes(line); err == nil {
+				return string(s)
+			}
+		}
+	}
+	return string(line)
+}
+
+// oemEncoding returns the encoding of the system OEM codepage, which console
+// programs write in, or nil if it is unknown.
+var oemEncoding = sync.OnceValue(func() encoding.Encoding {
+	switch oemCodePage() {
+	case 437:
+		return charmap.CodePage437
+	case 850:
+		return charmap.CodePage850
+	case 852:
+		return charmap.CodePage852
+	case 855:
+		return charmap.CodePage855
+	case 858:
+		return charmap.CodePage858
+	case 860:
+		return charmap.CodePage860
+	case 862:
+		return charmap.CodePage862
+	case 863:
+		return charmap.CodePage863
+	case 865:
+		return charmap.CodePage865
+	case 866:
+		return charmap.CodePage866
+	case 932:
+		return japanese.ShiftJIS
+	case 936:
+		return simplifiedchinese.GBK
+	case 949:
+		return korean.EUCKR
+	case 950:
+		return traditionalchinese.Big5
+	}
+	return nil
+})
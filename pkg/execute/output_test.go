@@ -0,0 +1,155 @@
+package execute
+
+import (
+	"install-it/pkg/storage"
+	"strings"
+	"testing"
+
+	"golang.org/x/text/encoding/unicode"
+)
+
+func TestLineWriter_SplitsAcrossWrites(t *testing.T) {
+	log := &OutputLog{}
+	w := &lineWriter{log: log, stream: "stdout"}
+
+	for _, chunk := range []string{"first li", "ne\r\nsecond\n", "third"} {
+		w.Write([]byte(chunk))
+	}
+	if got := len(log.Lines(0)); got != 2 {
+		t.Fatalf("lines before flush: got %d, want 2", got)
+	}
+	w.flush()
+
+	lines := log.Lines(0)
+	want := []string{"first line", "second", "third"}
+	if len(lines) != len(want) {
+		t.Fatalf("got %d lines, want %d: %v", len(lines), len(want), lines)
+	}
+	for i, line := range lines {
+		if line.Text != want[i] || line.Stream != "stdout" {
+			t.Errorf("line %d: got %+v, want %q on stdout", i, line, want[i])
+		}
+	}
+}
+
+func TestLineWriter_UTF16(t *testing.T) {
+	log := &OutputLog{}
+	w := &lineWriter{log: log, stream: "stdout"}
+
+	encoded, err := unicode.UTF16(unicode.LittleEndian, unicode.UseBOM).NewEncoder().Bytes([]byte("驅動程式\r\nsecond\r\n"))
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	// Split in the middle of a code unit to check buffering
+	w.Write(encoded[:5])
+	w.Write(encoded[5:])
+
+	lines := log.Lines(0)
+	if len(lines) != 2 || lines[0].Text != "驅動程式" || lines[1].Text != "second" {
+		t.Errorf("unexpected lines: %+v", lines)
+	}
+}
+
+func TestLineWriter_LongLineIsEmitted(t *testing.T) {
+	log := &OutputLog{}
+	w := &lineWriter{log: log, stream: "stderr"}
+
+	w.Write([]byte(strings.Repeat("x", maxLineLength+10)))
+
+	if got := len(log.Lines(0)); got != 1 {
+		t.Errorf("unterminated long line: got %d lines, want 1", got)
+	}
+}
+
+func TestOutputLog_Lines_Offset(t *testing.T) {
+	log := &OutputLog{}
+	for _, s := range []string{"a", "b", "c"} {
+		log.append(OutputLine{Stream: "stdout", Text: s})
+	}
+
+	if got := log.Lines(1); len(got) != 2 || got[0].Text != "b" {
+		t.Errorf("Lines(1): got %+v", got)
+	}
+	if got := log.Lines(3); got == nil || len(got) != 0 {
+		t.Errorf("Lines(3): got %+v, want empty slice", got)
+	}
+	if got := log.Lines(-1); len(got) != 0 {
+		t.Errorf("Lines(-1): got %+v, want empty slice", got)
+	}
+}
+
+func TestOutputLog_KeepsLastLines(t *testing.T) {
+	log := &OutputLog{limit: 6}
+	for _, s := range []string{"aa", "bb", "cc", "dd"} {
+		log.append(OutputLine{Stream: "stdout", Text: s})
+	}
+
+	lines := log.Lines(0)
+	if len(lines) != 3 || lines[0].Text != "bb" || lines[0].Line != 1 || lines[2].Line != 3 {
+		t.Fatalf("Lines(0): got %+v, want the last 3 lines", lines)
+	}
+	if got := log.Lines(3); len(got) != 1 || got[0].Text != "dd" {
+		t.Errorf("Lines(3): got %+v, want the line at the absolute offset", got)
+	}
+	if got := log.Lines(4); len(got) != 0 {
+		t.Errorf("Lines(4): got %+v, want empty slice", got)
+	}
+
+	// A line over the limit is kept on its own
+	log.append(OutputLine{Stream: "stderr", Text: "0123456789"})
+	if got := log.Lines(0); len(got) != 1 || got[0].Line != 4 {
+		t.Errorf("long line: got %+v", got)
+	}
+}
+
+func TestOutputLog_Tail(t *testing.T) {
+	log := &OutputLog{}
+	for _, s := range []string{"first", "second", "third"} {
+		log.append(OutputLine{Stream: "stdout", Text: s})
+	}
+
+	if got := log.tail(100); got != "first\nsecond\nthird\n" {
+		t.Errorf("tail(100): got %q", got)
+	}
+	if got := log.tail(9); got != "nd\nthird\n" {
+		t.Errorf("tail(9): got %q", got)
+	}
+	if got := (&OutputLog{}).tail(10); got != "" {
+		t.Errorf("empty log: got %q", got)
+	}
+}
+
+func TestLineWriter_MatchesPatterns(t *testing.T) {
+	patterns, err := compilePatterns(storage.Driver{
+		SuccessPatterns: []string{"(?i)success"},
+		FailurePatterns: []string{`error \d+`, "fatal"},
+	})
+	if err != nil {
+		t.Fatalf("compilePatterns: %v", err)
+	}
+	match := &outputMatch{patterns: patterns}
+	log := &OutputLog{limit: 10}
+	stdout := &lineWriter{log: log, stream: "stdout", match: match}
+	stderr := &lineWriter{log: log, stream: "stderr", match: match}
+
+	// Matches are kept after the lines have left the log
+	stderr.Write([]byte("fatal error 42\n"))
+	stdout.Write([]byte(strings.Repeat("filler\n", 10) + "Success\n"))
+
+	failure, success := match.matched()
+	if failure != `error \d+` || success != "(?i)success" {
+		t.Errorf("got %q, %q, want the first matching patterns", failure, success)
+	}
+	if lines := log.Lines(0); lines[0].Line == 0 {
+		t.Errorf("the first lines should have been dropped: %+v", lines)
+	}
+}
+
+func TestCompilePatterns(t *testing.T) {
+	if patterns, err := compilePatterns(storage.Driver{}); patterns != nil || err != nil {
+		t.Errorf("without patterns: got %v, %v", patterns, err)
+	}
+	if _, err := compilePatterns(storage.Driver{FailurePatterns: []string{"ok", "("}}); err == nil || !strings.Contains(err.Error(), `"("`) {
+		t.Errorf("expected an error naming the invalid pattern, got %v", err)
+	}
+}
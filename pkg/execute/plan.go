@@ -0,0 +1,200 @@
+package execute
+
+import (
+	"cmp"
+	"errors"
+	"install-it/pkg/storage"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// GroupReader provides read access to driver groups for planning.
+// It is satisfied by *storage.DriverGroupStorage.
+type GroupReader interface {
+	Get(id uint) (storage.DriverGroup, error)
+}
+
+// SettingReader provides read access to the app settings for planning.
+// It is satisfied by *storage.AppSettingStorage.
+type SettingReader interface {
+	All() (storage.AppSetting, error)
+}
+
+// InstallPlan is the input of InstallSession.Start.
+type InstallPlan struct {
+	MaxConcurrency int              `json:"maxConcurrency"` // Running commands at most, 0 for no limit
+	Commands       []PlannedCommand `json:"commands"`
+}
+
+// Planner builds install plans from the selected driver groups and the setting
+// tasks enabled in the app settings.
+type Planner struct {
+	groups    GroupReader
+	settings  SettingReader
+	expander  *Expander
+	launchers *Launchers
+}
+
+// NewPlanner creates a Planner with the given group and setting readers.
+// expander and launchers resolve the commands of dry runs, and may be nil.
+func NewPlanner(groups GroupReader, settings SettingReader, expander *Expander, launchers *Launchers) *Planner {
+	return &Planner{groups: groups, settings: settings, expander: expander, launchers: launchers}
+}
+
+// Plan returns the setting tasks followed by the drivers of the groups with
+// groupIds in the order of the groups, to be run with the concurrency of the
+// app settings.
+func (p *Planner) Plan(groupIds []uint) (InstallPlan, error) {
+	setting, err := p.settings.All()
+	if err != nil {
+		return InstallPlan{}, err
+	}
+	return p.PlanWith(groupIds, setting)
+}
+
+// PlanWith is like Plan, but takes the setting tasks and concurrency from
+// setting instead of the saved app settings, e.g. for changes not saved yet.
+func (p *Planner) PlanWith(groupIds []uint, setting storage.AppSetting) (InstallPlan, error) {
+	groups := make([]storage.DriverGroup, 0, len(groupIds))
+	for _, id := range groupIds {
+		group, err := p.groups.Get(id)
+		if err != nil {
+			return InstallPlan{}, err
+		}
+		groups = append(groups, group)
+	}
+	slices.SortStableFunc(groups, func(a, b storage.DriverGroup) int { return cmp.Compare(a.Position, b.Position) })
+
+	drivers, err := PlanDrivers(groups)
+	if err != nil {
+		return InstallPlan{}, err
+	}
+	return InstallPlan{
+		MaxConcurrency: setting.Concurrency(),
+		Commands:       append(SettingTasks(setting), drivers...),
+	}, nil
+}
+
+// SettingTasks returns the planned commands of the tasks enabled in setting.
+func SettingTasks(setting storage.AppSetting) []PlannedCommand {
+	cmds := []PlannedCommand{}
+	if setting.SetPassword {
+		password := "(new-object System.Security.SecureString)"
+		if setting.Password != "" {
+			password = "(ConvertTo-SecureString '" + strings.ReplaceAll(setting.Password, "'", "''") + "' -AsPlainText -Force)"
+		}
+		cmds = append(cmds, settingTask("set_password", 0.5,
+			"Set-LocalUser -Name $Env:UserName -Password "+password))
+	}
+	if setting.CreatePartition {
+		cmds = append(cmds, settingTask("create_partition", 1,
+			`Get-Disk | Where-Object PartitionStyle -Eq "RAW" | Initialize-Disk -PassThru | New-Partition -AssignDriveLetter -UseMaximumSize | Format-Volume`))
+	}
+	return cmds
+}
+
+func settingTask(id string, minExeTime float32, script string) PlannedCommand {
+	return PlannedCommand{
+		Id:   id,
+		Name: id,
+		Driver: storage.Driver{
+			Path:         "powershell",
+			Flags:        []string{"-WindowStyle", "Hidden", "-Command", script},
+			MinExeTime:   minExeTime,
+			AllowRtCodes: []int32{0},
+		},
+		Incompatibles: []string{},
+		DependsOn:     []string{},
+	}
+}
+
+// PlanDrivers returns the planned commands of every driver of groups. Drivers of
+// mutually exclusive groups are incompatible with each other, Windows Installer
+// packages hold MsiLock, and dependencies on drivers outside the plan are
+// dropped. Commands are ordered topologically, keeping the order of groups for
+// drivers without dependencies between them, so that a serial session installs
+// dependencies first.
+func PlanDrivers(groups []storage.DriverGroup) ([]PlannedCommand, error) {
+	var cmds []PlannedCommand
+	planned := make(map[string]bool)
+	for _, group := range groups {
+		for _, driver := range group.Drivers {
+			planned[driverId(driver.Id)] = true
+		}
+	}
+
+	for _, group := range groups {
+		for _, driver := range group.Drivers {
+			cmd := PlannedCommand{
+				Id:            driverId(driver.Id),
+				Name:          driver.Name,
+				GroupName:     group.Name,
+				Driver:        *driver,
+				Incompatibles: []string{},
+				DependsOn:     []string{},
+				GroupId:       group.Id,
+				GroupLimit:    group.MaxConcurrency,
+				Locks:         slices.Clone(driver.Locks),
+			}
+			if isMsi(driver.Path) && !slices.Contains(cmd.Locks, MsiLock) {
+				cmd.Locks = append(cmd.Locks, MsiLock)
+			}
+			for _, id := range driver.IncompatibleIds {
+				cmd.Incompatibles = append(cmd.Incompatibles, driverId(id))
+			}
+			if group.MutuallyExclusive {
+				for _, other := range group.Drivers {
+					if id := driverId(other.Id); other != driver && !slices.Contains(cmd.Incompatibles, id) {
+						cmd.Incompatibles = append(cmd.Incompatibles, id)
+					}
+				}
+			}
+			for _, id := range driver.DependsOnIds {
+				if planned[driverId(id)] {
+					cmd.DependsOn = append(cmd.DependsOn, driverId(id))
+				}
+			}
+			cmds = append(cmds, cmd)
+		}
+	}
+
+	return sortByDependencies(cmds)
+}
+
+// sortByDependencies orders cmds so that every command comes after its
+// dependencies, otherwise keeping their order.
+func sortByDependencies(cmds []PlannedCommand) ([]PlannedCommand, error) {
+	sorted := make([]PlannedCommand, 0, len(cmds))
+	done := make(map[string]bool, len(cmds))
+	for len(sorted) < len(cmds) {
+		progressed := false
+		for _, cmd := range cmds {
+			if done[cmd.Id] || slices.ContainsFunc(cmd.DependsOn, func(id string) bool { return !done[id] }) {
+				continue
+			}
+			sorted = append(sorted, cmd)
+			done[cmd.Id], progressed = true, true
+			break
+		}
+		if !progressed {
+			return nil, errors.New("execute: dependency cycle in plan")
+		}
+	}
+	return sorted, nil
+}
+
+// MsiLock is the lock held by Windows Installer packages, since a second
+// package fails with 1618 while another one is installing.
+const MsiLock = "msi"
+
+// isMsi reports whether path runs the Windows Installer.
+func isMsi(path string) bool {
+	name := strings.ToLower(filepath.Base(path))
+	return filepath.Ext(name) == ".msi" || name == "msiexec" || name == "msiexec.exe"
+}
+
+func driverId(id uint) string {
+	return strconv.FormatUint(uint64(id), 10)
+}
@@ -0,0 +1,99 @@
+package execute_test
+
+import (
+	"slices"
+	"testing"
+
+	"install-it/pkg/execute"
+	"install-it/pkg/storage"
+)
+
+func ids(cmds []execute.PlannedCommand) []string {
+	result := make([]string, len(cmds))
+	for i, cmd := range cmds {
+		result[i] = cmd.Id
+	}
+	return result
+}
+
+func TestPlanDrivers_OrdersByDependencies(t *testing.T) {
+	t.Parallel()
+
+	groups := []storage.DriverGroup{
+		{Name: "Audio", Drivers: []*storage.Driver{{Id: 3, Name: "Audio", DependsOnIds: []uint{1}}}},
+		{Name: "Network", Drivers: []*storage.Driver{{Id: 4, Name: "LAN", DependsOnIds: []uint{99}}}},
+		{Name: "Chipset", Drivers: []*storage.Driver{{Id: 1, Name: "Chipset"}, {Id: 2, Name: "ME", DependsOnIds: []uint{1}}}},
+	}
+
+	cmds, err := execute.PlanDrivers(groups)
+	if err != nil {
+		t.Fatalf("PlanDrivers: %v", err)
+	}
+
+	if got, want := ids(cmds), []string{"4", "1", "3", "2"}; !slices.Equal(got, want) {
+		t.Errorf("order: got %v, want %v", got, want)
+	}
+	if cmds[0].GroupName != "Network" || len(cmds[0].DependsOn) != 0 {
+		t.Errorf("dependencies outside the plan should be dropped: %+v", cmds[0])
+	}
+	if !slices.Equal(cmds[2].DependsOn, []string{"1"}) {
+		t.Errorf("audio dependencies: got %v", cmds[2].DependsOn)
+	}
+}
+
+func TestPlanDrivers_MutuallyExclusive(t *testing.T) {
+	t.Parallel()
+
+	groups := []storage.DriverGroup{{Name: "GPU", MutuallyExclusive: true, Drivers: []*storage.Driver{
+		{Id: 1, IncompatibleIds: []uint{2}},
+		{Id: 2},
+		{Id: 3},
+	}}}
+
+	cmds, err := execute.PlanDrivers(groups)
+	if err != nil {
+		t.Fatalf("PlanDrivers: %v", err)
+	}
+	if got := cmds[0].Incompatibles; !slices.Equal(got, []string{"2", "3"}) {
+		t.Errorf("incompatibles of 1: got %v, want [2 3]", got)
+	}
+	if got := cmds[2].Incompatibles; !slices.Equal(got, []string{"1", "2"}) {
+		t.Errorf("incompatibles of 3: got %v, want [1 2]", got)
+	}
+}
+
+func TestPlanDrivers_Cycle(t *testing.T) {
+	t.Parallel()
+
+	groups := []storage.DriverGroup{{Drivers: []*storage.Driver{
+		{Id: 1, DependsOnIds: []uint{2}},
+		{Id: 2, DependsOnIds: []uint{1}},
+	}}}
+	if _, err := execute.PlanDrivers(groups); err == nil {
+		t.Error("expected error for a dependency cycle, got nil")
+	}
+}
+
+func TestPlanDrivers_LimitsAndLocks(t *testing.T) {
+	t.Parallel()
+
+	groups := []storage.DriverGroup{{Id: 7, MaxConcurrency: 2, Drivers: []*storage.Driver{
+		{Id: 1, Path: `drivers\setup.exe`, Locks: []string{"disk"}},
+		{Id: 2, Path: `drivers\Intel.MSI`},
+		{Id: 3, Path: "msiexec", Locks: []string{"msi"}},
+	}}}
+
+	cmds, err := execute.PlanDrivers(groups)
+	if err != nil {
+		t.Fatalf("PlanDrivers: %v", err)
+	}
+	want := [][]string{{"disk"}, {"msi"}, {"msi"}}
+	for i, cmd := range cmds {
+		if cmd.GroupId != 7 || cmd.GroupLimit != 2 {
+			t.Errorf("%s: group %d limit %d, want 7 and 2", cmd.Id, cmd.GroupId, cmd.GroupLimit)
+		}
+		if !slices.Equal(cmd.Locks, want[i]) {
+			t.Errorf("%s: locks %v, want %v", cmd.Id, cmd.Locks, want[i])
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package execute
+
+import (
+	"cmp"
+	"errors"
+	"install-it/pkg/status"
+	"slices"
+	"time"
+)
+
+// Retention limits the finished commands a CommandExecutor keeps for Result
+// and List. Running commands are always kept. A zero MaxAge or MaxCount does
+// not limit.
+type Retention struct {
+	MaxAge   time.Duration    // Finished commands are forgotten this long after finishing
+	MaxCount int              // Finished commands kept at most, the oldest are forgotten first
+	Now      func() time.Time // Clock for MaxAge, time.Now when nil
+}
+
+// defaultRetention is used by a CommandExecutor without Retention.
+var defaultRetention = &Retention{MaxAge: time.Hour, MaxCount: 100}
+
+func (r *Retention) now() time.Time {
+	if r.Now != nil {
+		return r.Now()
+	}
+	return time.Now()
+}
+
+// CommandState is the status and outcome of a command started by Run or
+// RunDriver, polled by clients that missed its "execute:exited" event.
+type CommandState struct {
+	Id         string         `json:"id"`
+	Name       string         `json:"name"`
+	Status     status.Status  `json:"status"` // Running until finished, then the Status of Result
+	StartedAt  time.Time      `json:"startedAt"`
+	FinishedAt time.Time      `json:"finishedAt"` // Zero while running
+	Result     *CommandResult `json:"result"`     // Nil while running
+}
+
+// Result returns the state of the command id, which is available until the
+// command is forgotten by Forget or the retention policy.
+func (ce *CommandExecutor) Result(id string) (CommandState, error) {
+	ce.prune()
+
+	task, ok := ce.commands.Load(id)
+	if !ok {
+		return CommandState{}, errors.New("execute: id not found")
+	}
+	return task.state(id), nil
+}
+
+// List returns the state of every command kept, the earliest started first.
+func (ce *CommandExecutor) List() []CommandState {
+	ce.prune()
+
+	states := []CommandState{}
+	ce.commands.Range(func(id string, task *task) bool {
+		states = append(states, task.state(id))
+		return true
+	})
+	slices.SortFunc(states, func(a, b CommandState) int {
+		if c := a.StartedAt.Compare(b.StartedAt); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Id, b.Id)
+	})
+	return states
+}
+
+// Forget drops the finished command id with its result and output. Running
+// commands must be aborted and finish first.
+func (ce *CommandExecutor) Forget(id string) error {
+	task, ok := ce.commands.Load(id)
+	if !ok {
+		return errors.New("execute: id not found")
+	}
+	if _, done := task.finished(); !done {
+		return errors.New("execute: command is still running")
+	}
+	ce.commands.Delete(id)
+	return nil
+}
+
+// prune forgets the finished commands exceeding the retention policy.
+func (ce *CommandExecutor) prune() {
+	retention := ce.retention()
+	now := retention.now()
+
+	type finishedTask struct {
+		id string
+		at time.Time
+	}
+	var kept []finishedTask
+	ce.commands.Range(func(id string, task *task) bool {
+		at, done := task.finished()
+		switch {
+		case !done:
+		case retention.MaxAge > 0 && now.Sub(at) > retention.MaxAge:
+			ce.commands.Delete(id)
+		default:
+			kept = append(kept, finishedTask{id, at})
+		}
+		return true
+	})
+
+	if retention.MaxCount <= 0 || len(kept) <= retention.MaxCount {
+		return
+	}
+	slices.SortFunc(kept, func(a, b finishedTask) int { return a.at.Compare(b.at) })
+	for _, t := range kept[:len(kept)-retention.MaxCount] {
+		ce.commands.Delete(t.id)
+	}
+}
+
+func (ce *CommandExecutor) retention() *Retention {
+	if ce.Retention == nil {
+		return defaultRetention
+	}
+	return ce.Retention
+}
+
+// state returns the state of the task with the given id.
+func (t *task) state(id string) CommandState {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	state := CommandState{
+		Id:         id,
+		Name:       t.name(),
+		Status:     status.Running,
+		StartedAt:  t.startedAt,
+		FinishedAt: t.finishedAt,
+	}
+	if t.result != nil {
+		result := *t.result
+		state.Status, state.Result = result.Status, &result
+	}
+	return state
+}
+
+// finish records the result of the task.
+func (t *task) finish(result CommandResult, at time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.result, t.finishedAt = &result, at
+}
+
+// finished returns when the task finished, and whether it has.
+func (t *task) finished() (time.Time, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.finishedAt, t.result != nil
+}
@@ -0,0 +1,123 @@
+package execute
+
+import (
+	"encoding/json"
+	"errors"
+	"install-it/pkg/status"
+	"os"
+	"slices"
+)
+
+// SessionState is the state of a running session saved to StatePath, so that
+// the session can be resumed after a reboot. Only the Command and Status of
+// the steps are saved, since their results may hold large output.
+type SessionState struct {
+	MaxConcurrency int    `json:"maxConcurrency"`
+	Steps          []Step `json:"steps"`
+}
+
+// RebootLauncher registers the app to be launched once after the next reboot.
+// It is satisfied by RunOnceLauncher (and fakes in tests).
+type RebootLauncher interface {
+	Register() error
+	Unregister() error
+}
+
+// ResumableSteps returns the steps of the saved session that were not
+// finished, or nothing if there is no saved session.
+func (s *InstallSession) ResumableSteps() ([]Step, error) {
+	state, err := s.loadState()
+	if err != nil {
+		return nil, err
+	}
+	return state.resumable(), nil
+}
+
+// Resume starts a session with the unfinished steps of the saved session.
+// Steps that were running when the session was interrupted are run again.
+func (s *InstallSession) Resume() error {
+	state, err := s.loadState()
+	if err != nil {
+		return err
+	}
+
+	steps := state.resumable()
+	cmds := make([]PlannedCommand, len(steps))
+	for i, step := range steps {
+		cmds[i] = step.Command
+	}
+	return s.Start(state.MaxConcurrency, cmds)
+}
+
+// Discard removes the saved session, so that it is not offered for resuming.
+func (s *InstallSession) Discard() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.status == status.Running {
+		return errors.New("execute: session is running")
+	}
+	return s.discard()
+}
+
+func (state SessionState) resumable() []Step {
+	steps := []Step{}
+	for _, step := range state.Steps {
+		if slices.Contains([]status.Status{status.Pending, status.Running, status.Aborting}, step.Status) {
+			steps = append(steps, step)
+		}
+	}
+	return steps
+}
+
+func (s *InstallSession) loadState() (SessionState, error) {
+	var state SessionState
+	if s.StatePath == "" {
+		return state, nil
+	}
+
+	bytes, err := os.ReadFile(s.StatePath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return state, nil
+		}
+		return state, err
+	}
+	return state, json.Unmarshal(bytes, &state)
+}
+
+// save writes the session state to StatePath. Callers must hold s.mu.
+func (s *InstallSession) save() {
+	if s.StatePath == "" || s.status != status.Running {
+		return
+	}
+
+	state := SessionState{MaxConcurrency: s.maxConcurrency, Steps: make([]Step, len(s.steps))}
+	for i, step := range s.steps {
+		state.Steps[i] = Step{Command: step.Command, Status: step.Status}
+	}
+
+	bytes, err := json.Marshal(state)
+	if err == nil {
+		err = os.WriteFile(s.StatePath, bytes, 0644)
+	}
+	if err != nil {
+		s.err = err
+	}
+}
+
+// discard removes the saved state and the reboot launch. Callers must hold s.mu.
+func (s *InstallSession) discard() error {
+	if s.StatePath == "" {
+		return nil
+	}
+
+	var errs error
+	if err := os.Remove(s.StatePath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		errs = err
+	}
+	if s.Launcher != nil {
+		errs = errors.Join(errs, s.Launcher.Unregister())
+	}
+	return errs
+}
@@ -0,0 +1,121 @@
+package execute_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"install-it/pkg/execute"
+	"install-it/pkg/status"
+)
+
+// fakeLauncher counts launch registrations.
+type fakeLauncher struct {
+	registered int
+}
+
+func (f *fakeLauncher) Register() error {
+	f.registered++
+	return nil
+}
+
+func (f *fakeLauncher) Unregister() error {
+	f.registered = 0
+	return nil
+}
+
+// copyFile copies the saved state, as if the machine rebooted while it was saved.
+func copyFile(t *testing.T, src, dst string) {
+	t.Helper()
+	bytes, err := os.ReadFile(src)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if err := os.WriteFile(dst, bytes, 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+}
+
+func TestInstallSession_Resume_SkipsCompletedSteps(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	statePath := filepath.Join(dir, "session.json")
+	launcher := &fakeLauncher{}
+
+	runner := newFakeRunner("a", "b", "c")
+	s := &execute.InstallSession{Runner: runner, StatePath: statePath, Launcher: launcher}
+	if err := s.Start(1, []execute.PlannedCommand{planned("a"), planned("b"), planned("c")}); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if launcher.registered != 1 {
+		t.Errorf("launch after reboot should be registered on Start")
+	}
+
+	waitStarted(t, runner, "a")
+	runner.finish("a", execute.CommandResult{Stdout: "installed a"})
+	waitStarted(t, runner, "b")
+	s.Snapshot() // Waits for the state saved after a finished
+	if saved, _ := os.ReadFile(statePath); bytes.Contains(saved, []byte("installed a")) {
+		t.Errorf("the output of finished steps should not be saved: %s", saved)
+	}
+
+	// "Reboot" while b is running
+	rebootPath := filepath.Join(dir, "rebooted.json")
+	copyFile(t, statePath, rebootPath)
+
+	resumeRunner := newFakeRunner("b", "c")
+	resumed := &execute.InstallSession{Runner: resumeRunner, StatePath: rebootPath, Launcher: launcher}
+
+	steps, err := resumed.ResumableSteps()
+	if err != nil {
+		t.Fatalf("ResumableSteps: %v", err)
+	}
+	if len(steps) != 2 || steps[0].Command.Id != "b" || steps[1].Command.Id != "c" {
+		t.Fatalf("unexpected resumable steps: %+v", steps)
+	}
+
+	if err := resumed.Resume(); err != nil {
+		t.Fatalf("Resume: %v", err)
+	}
+	waitStarted(t, resumeRunner, "b")
+	resumeRunner.finish("b", execute.CommandResult{})
+	waitStarted(t, resumeRunner, "c")
+	resumeRunner.finish("c", execute.CommandResult{})
+	resumed.Wait()
+
+	if snap := resumed.Snapshot(); snap.Status != status.Completed || len(snap.Steps) != 2 {
+		t.Errorf("unexpected resumed session: %+v", snap)
+	}
+	if _, err := os.Stat(rebootPath); !os.IsNotExist(err) {
+		t.Errorf("state file should be removed once the session finishes, stat error: %v", err)
+	}
+	if launcher.registered != 0 {
+		t.Errorf("launch after reboot should be unregistered once the session finishes")
+	}
+
+	s.Abort("b")
+	s.Abort("c")
+	s.Wait()
+}
+
+func TestInstallSession_Discard(t *testing.T) {
+	t.Parallel()
+
+	statePath := filepath.Join(t.TempDir(), "session.json")
+	if err := os.WriteFile(statePath, []byte(`{"maxConcurrency":1,"steps":[{"command":{"id":"a"},"status":"pending"}]}`), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	s := &execute.InstallSession{StatePath: statePath}
+	if steps, err := s.ResumableSteps(); err != nil || len(steps) != 1 {
+		t.Fatalf("ResumableSteps: got %v, %v", steps, err)
+	}
+	if err := s.Discard(); err != nil {
+		t.Fatalf("Discard: %v", err)
+	}
+	if steps, err := s.ResumableSteps(); err != nil || len(steps) != 0 {
+		t.Errorf("ResumableSteps after Discard: got %v, %v", steps, err)
+	}
+}
@@ -0,0 +1,89 @@
+package execute
+
+import (
+	"context"
+	"install-it/pkg/status"
+	"install-it/pkg/storage"
+	"slices"
+	"time"
+)
+
+// Attempt is the outcome of a single run of a command.
+type Attempt struct {
+	Lapse    float32       `json:"lapse"`
+	ExitCode int           `json:"exitCode"`
+	Error    string        `json:"error"`
+	Status   status.Status `json:"status"`
+}
+
+// runPackaged runs driver like runDriver, after extracting it from its archive
+// and resolving the launcher of its type. A driver that cannot be extracted
+// fails without running.
+func runPackaged(ctx context.Context, archives *Archives, launchers *Launchers, driver storage.Driver, output *OutputLog) CommandResult {
+	unpacked, cleanup, err := archives.unpack(driver)
+	if err != nil {
+		result := CommandResult{CommandLine: commandLine(launchers.Launch(previewArchive(driver))), ExitCode: -1, Error: err.Error()}
+		result.Status = Classify(driver, result)
+		return result
+	}
+
+	result := runDriver(ctx, launchers.Launch(unpacked), output)
+	if err := cleanup(result); err != nil && result.Error == "" {
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// runDriver runs the driver until it succeeds or its retry policy is
+// exhausted, and returns the result of the last attempt with every attempt
+// recorded. A failed attempt is retried when its exit code is retryable.
+// Cancelling ctx stops the running attempt and prevents further ones. Once the
+// driver succeeded, its verification check decides whether it is unverified.
+func runDriver(ctx context.Context, driver storage.Driver, output *OutputLog) CommandResult {
+	result := runAttempts(ctx, driver, output)
+	if succeeded(result.Status) || result.Status == status.Speeded {
+		if result.Verification = verify(ctx, driver); result.Verification != nil {
+			result.Status = Classify(driver, result)
+		}
+	}
+	return result
+}
+
+func runAttempts(ctx context.Context, driver storage.Driver, output *OutputLog) CommandResult {
+	patterns, err := compilePatterns(driver)
+	if err != nil {
+		result := CommandResult{CommandLine: commandLine(driver), ExitCode: -1, Error: err.Error()}
+		result.Status = Classify(driver, result)
+		return result
+	}
+
+	policy := driver.Retry
+	delay := seconds(policy.Delay)
+
+	var attempts []Attempt
+	for {
+		command := newCommand(driver, output)
+		command.matchOutput(patterns)
+		result := command.result(command.supervise(ctx))
+		attempts = append(attempts, Attempt{result.Lapse, result.ExitCode, result.Error, result.Status})
+		result.CommandLine, result.Attempts = commandLine(driver), attempts
+		result.ExitCodeInfo = DescribeExitCode(driver, result.ExitCode)
+
+		if len(attempts) >= policy.MaxAttempts || result.Status != status.Failed ||
+			len(policy.ExitCodes) > 0 && !slices.Contains(policy.ExitCodes, int32(result.ExitCode)) {
+			return result
+		}
+
+		select {
+		case <-ctx.Done():
+			result.Aborted = true
+			result.Status = Classify(driver, result)
+			return result
+		case <-time.After(delay):
+		}
+
+		if policy.Backoff > 1 {
+			delay = time.Duration(float64(delay) * float64(policy.Backoff))
+		}
+	}
+}
@@ -0,0 +1,18 @@
+//go:build !windows
+
+package execute
+
+import "errors"
+
+// RunOnceLauncher is only supported on Windows.
+type RunOnceLauncher struct {
+	Name string // Name of the registry value
+}
+
+func (l RunOnceLauncher) Register() error {
+	return errors.New("execute: launch after reboot is only supported on Windows")
+}
+
+func (l RunOnceLauncher) Unregister() error {
+	return nil
+}
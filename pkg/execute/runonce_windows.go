@@ -0,0 +1,49 @@
+//go:build windows
+
+package execute
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/windows/registry"
+)
+
+const runOnceKey = `Software\Microsoft\Windows\CurrentVersion\RunOnce`
+
+// RunOnceLauncher launches the running executable after the next logon
+// through the RunOnce registry key of the current user.
+type RunOnceLauncher struct {
+	Name string // Name of the registry value
+}
+
+func (l RunOnceLauncher) Register() error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	key, _, err := registry.CreateKey(registry.CURRENT_USER, runOnceKey, registry.SET_VALUE)
+	if err != nil {
+		return err
+	}
+	defer key.Close()
+
+	return key.SetStringValue(l.Name, `"`+exe+`"`)
+}
+
+func (l RunOnceLauncher) Unregister() error {
+	key, err := registry.OpenKey(registry.CURRENT_USER, runOnceKey, registry.SET_VALUE)
+	if err != nil {
+		if errors.Is(err, registry.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	defer key.Close()
+
+	if err := key.DeleteValue(l.Name); err != nil && !errors.Is(err, registry.ErrNotExist) {
+		return err
+	}
+	return nil
+}
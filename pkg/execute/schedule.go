@@ -0,0 +1,83 @@
+package execute
+
+import (
+	"install-it/pkg/status"
+	"slices"
+)
+
+// schedule calls start for every pending step allowed to start now: its
+// dependencies have succeeded, fewer than maxConcurrency steps (0 for no limit)
+// and fewer than its group limit are running, and no running step is
+// incompatible with it or holds one of its locks. start must mark the step as
+// running, so that the rules apply to the steps after it.
+func schedule(steps []*sessionStep, maxConcurrency int, start func(*sessionStep)) {
+	skipUnsatisfiable(steps)
+
+	for _, step := range steps {
+		if step.Status != status.Pending {
+			continue
+		}
+		if maxConcurrency > 0 && countRunning(steps, nil) >= maxConcurrency {
+			break
+		}
+		if slices.ContainsFunc(steps, func(other *sessionStep) bool {
+			return slices.Contains(step.Command.DependsOn, other.Command.Id) && !succeeded(other.Status)
+		}) {
+			continue
+		}
+		if slices.ContainsFunc(steps, func(other *sessionStep) bool {
+			return other.Status == status.Running && slices.Contains(step.Command.Incompatibles, other.Command.Id)
+		}) {
+			continue
+		}
+		if step.Command.GroupLimit > 0 && countRunning(steps, func(other *sessionStep) bool {
+			return other.Command.GroupId == step.Command.GroupId
+		}) >= step.Command.GroupLimit {
+			continue
+		}
+		if countRunning(steps, func(other *sessionStep) bool {
+			return slices.ContainsFunc(step.Command.Locks, func(lock string) bool { return slices.Contains(other.Command.Locks, lock) })
+		}) > 0 {
+			continue
+		}
+
+		start(step)
+	}
+}
+
+// skipUnsatisfiable skips pending steps with a dependency that has finished
+// without success, including transitively skipped ones.
+func skipUnsatisfiable(steps []*sessionStep) {
+	for changed := true; changed; {
+		changed = false
+		for _, step := range steps {
+			if step.Status == status.Pending && slices.ContainsFunc(steps, func(other *sessionStep) bool {
+				return slices.Contains(step.Command.DependsOn, other.Command.Id) && finished(other.Status) && !succeeded(other.Status)
+			}) {
+				step.Status, changed = status.Skiped, true
+			}
+		}
+	}
+}
+
+// countRunning returns the number of steps with a live process that match
+// filter, or of all of them when filter is nil.
+func countRunning(steps []*sessionStep, filter func(*sessionStep) bool) int {
+	n := 0
+	for _, st := range steps {
+		if (st.Status == status.Running || st.Status == status.Aborting) && (filter == nil || filter(st)) {
+			n++
+		}
+	}
+	return n
+}
+
+// succeeded reports whether a step with the status installed successfully.
+func succeeded(st status.Status) bool {
+	return st == status.Completed || st == status.RebootRequired
+}
+
+// finished reports whether a step with the status is done.
+func finished(st status.Status) bool {
+	return st != status.Pending && st != status.Running && st != status.Aborting
+}
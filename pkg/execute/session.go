@@ -0,0 +1,433 @@
+package execute
+
+import (
+	"context"
+	"errors"
+	"install-it/pkg/event"
+	"install-it/pkg/status"
+	"install-it/pkg/storage"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+// historyTailLimit is the number of bytes of output kept in the history of a step.
+const historyTailLimit = 4096
+
+// PlannedCommand is a single entry of an install plan. Id is the driver id for
+// drivers, or a task name (e.g. "set_password") for setting tasks.
+type PlannedCommand struct {
+	Id            string         `json:"id"`
+	Name          string         `json:"name"`
+	GroupName     string         `json:"groupName"`
+	Driver        storage.Driver `json:"driver"`        // Path, Flags and outcome rules of the command
+	Incompatibles []string       `json:"incompatibles"` // Ids that must not run at the same time
+	DependsOn     []string       `json:"dependsOn"`     // Ids that must succeed before this command starts
+	GroupId       uint           `json:"groupId"`       // Driver group of the command, 0 for setting tasks
+	GroupLimit    int            `json:"groupLimit"`    // Running commands of the group at most, 0 for no limit
+	Locks         []string       `json:"locks"`         // Named resources held exclusively while running, e.g. "msi"
+}
+
+// Step is the state of a PlannedCommand within an InstallSession.
+type Step struct {
+	Command    PlannedCommand `json:"command"`
+	Status     status.Status  `json:"status"`
+	Result     *CommandResult `json:"result"`
+	StartedAt  time.Time      `json:"startedAt"`
+	FinishedAt time.Time      `json:"finishedAt"`
+}
+
+// SessionSnapshot is a point-in-time view of the session, polled by the frontend.
+type SessionSnapshot struct {
+	Status         status.Status `json:"status"`         // pending|running|completed|failed|aborted
+	Paused         bool          `json:"paused"`         // Pending steps are not started until Continue
+	MaxConcurrency int           `json:"maxConcurrency"` // Running commands at most, 0 for no limit
+	Steps          []Step        `json:"steps"`
+	Error          string        `json:"error"` // Last error of recording the history, saving the state or keeping the logs
+	// Any step requires a reboot for its changes to take effect
+	RebootRequired bool `json:"rebootRequired"`
+}
+
+// Runner runs a single planned command to completion, appending its output
+// lines to output while it runs. Cancelling ctx must stop the command, in which
+// case the returned result is marked as aborted.
+// It is satisfied by ProcessRunner (and fakes in tests).
+type Runner interface {
+	Run(ctx context.Context, cmd PlannedCommand, output *OutputLog) CommandResult
+}
+
+// ProcessRunner runs planned commands as OS processes.
+type ProcessRunner struct {
+	Expander  *Expander  // Expands placeholders of the commands, none are expanded when nil
+	Launchers *Launchers // Launches installers by their type, the built-in types when nil
+	Archives  *Archives  // Extracts installers packaged in archives, to the temporary directory when nil
+}
+
+func (r ProcessRunner) Run(ctx context.Context, cmd PlannedCommand, output *OutputLog) CommandResult {
+	return runPackaged(ctx, r.Archives, r.Launchers, r.Expander.Expand(cmd.Driver), output)
+}
+
+// HistoryRecorder persists the history of sessions.
+// It is satisfied by *storage.InstallHistoryStorage.
+type HistoryRecorder interface {
+	StartRun(machine string, startedAt time.Time) (uint, error)
+	AddStep(runId uint, step storage.InstallStep) error
+	FinishRun(runId uint, runStatus status.Status, finishedAt time.Time) error
+}
+
+// InstallSession runs an install plan in the background, so that the state of
+// running installers outlives the frontend. Only one plan runs at a time —
+// calling Start while a plan is running will be rejected.
+type InstallSession struct {
+	Runner    Runner          // Runner used to spawn commands, ProcessRunner when nil
+	History   HistoryRecorder // Recorder of finished steps, nothing is recorded when nil
+	StatePath string          // File the session state is saved to for resuming, not saved when empty
+	Launcher  RebootLauncher  // Relaunches the app after a reboot while a session with StatePath runs
+	Logs      *LogStore       // Keeps the full output of steps under a directory per session, not kept when nil
+	// Receives "session:step" with the finished Step and "session:finished"
+	// with the final SessionSnapshot, discarded when nil. Events are published
+	// while the session is locked, so listeners must not call back into it.
+	Events event.Publisher
+
+	mu             sync.Mutex
+	status         status.Status
+	maxConcurrency int
+	paused         bool
+	aborted        bool // AbortAll was called
+	steps          []*sessionStep
+	done           chan struct{}
+	runId          uint
+	logRun         string // Directory of the step logs in Logs
+	err            error  // Last error of recording the history, saving the state or keeping the logs
+}
+
+type sessionStep struct {
+	Step
+	output *OutputLog
+	cancel context.CancelFunc
+}
+
+// Start runs cmds in the background. A command waits for its dependencies in
+// the plan to succeed, and is skipped if any of them did not. At most
+// maxConcurrency commands run at once (0 for no limit, 1 to run them one after
+// another), and a command only starts while none of its incompatibles or
+// commands holding one of its locks is running and its group is below its
+// limit.
+func (s *InstallSession) Start(maxConcurrency int, cmds []PlannedCommand) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.status == status.Running {
+		return errors.New("execute: session already running")
+	}
+	if len(cmds) == 0 {
+		return errors.New("execute: nothing to install")
+	}
+
+	s.runId, s.err = 0, nil
+	s.logRun = time.Now().Format("20060102-150405")
+	if s.History != nil {
+		machine, err := os.Hostname()
+		if err != nil {
+			return err
+		}
+		if s.runId, err = s.History.StartRun(machine, time.Now()); err != nil {
+			return err
+		}
+	}
+
+	s.status = status.Running
+	s.paused, s.aborted = false, false
+	s.maxConcurrency = max(maxConcurrency, 0)
+	s.done = make(chan struct{})
+	s.steps = make([]*sessionStep, len(cmds))
+	for i, cmd := range cmds {
+		s.steps[i] = &sessionStep{Step: Step{Command: cmd, Status: status.Pending}, output: &OutputLog{}}
+	}
+
+	if s.StatePath != "" && s.Launcher != nil {
+		if err := s.Launcher.Register(); err != nil {
+			s.err = err
+		}
+	}
+
+	s.dispatch()
+	s.save()
+	return nil
+}
+
+// Abort aborts the step with the given command id. A pending step is aborted
+// immediately; a running step is marked as aborting until its process exits.
+func (s *InstallSession) Abort(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	step := s.step(id)
+	if step == nil {
+		return errors.New("execute: id not found")
+	}
+
+	switch step.Status {
+	case status.Pending:
+		step.Status = status.Aborted
+		s.dispatch()
+		s.save()
+	case status.Running:
+		step.Status = status.Aborting
+		step.cancel()
+	default:
+		return errors.New("execute: step is not running")
+	}
+	return nil
+}
+
+// Pause stops starting pending steps until Continue is called. Running steps
+// keep running.
+func (s *InstallSession) Pause() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.status != status.Running {
+		return errors.New("execute: session is not running")
+	}
+	s.paused = true
+	return nil
+}
+
+// Continue starts pending steps again after Pause.
+func (s *InstallSession) Continue() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.status != status.Running {
+		return errors.New("execute: session is not running")
+	}
+	s.paused = false
+	s.dispatch()
+	s.save()
+	return nil
+}
+
+// AbortAll skips every pending step and aborts every running one. The session
+// finishes as aborted once the processes of the running steps have exited.
+func (s *InstallSession) AbortAll() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.status != status.Running {
+		return errors.New("execute: session is not running")
+	}
+
+	s.aborted, s.paused = true, false
+	for _, step := range s.steps {
+		switch step.Status {
+		case status.Pending:
+			step.Status = status.Skiped
+		case status.Running:
+			step.Status = status.Aborting
+			step.cancel()
+		}
+	}
+	s.dispatch()
+	s.save()
+	return nil
+}
+
+// Snapshot returns a copy of the current session state.
+func (s *InstallSession) Snapshot() SessionSnapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.snapshot()
+}
+
+// snapshot returns a copy of the current session state. Callers must hold s.mu.
+func (s *InstallSession) snapshot() SessionSnapshot {
+	snapshot := SessionSnapshot{
+		Status:         s.status,
+		Paused:         s.paused,
+		MaxConcurrency: s.maxConcurrency,
+		Steps:          make([]Step, len(s.steps)),
+	}
+	if s.err != nil {
+		snapshot.Error = s.err.Error()
+	}
+	if snapshot.Status == "" {
+		snapshot.Status = status.Pending
+	}
+	for i, step := range s.steps {
+		snapshot.Steps[i] = step.Step
+		snapshot.RebootRequired = snapshot.RebootRequired || step.Status == status.RebootRequired
+	}
+	return snapshot
+}
+
+// SuccessAction returns the action to take after the session succeeded when
+// the user chose action. Nothing is turned into Reboot if any step requires
+// a reboot, since every other action restarts the machine anyway.
+func (s *InstallSession) SuccessAction(action storage.SuccessAction) storage.SuccessAction {
+	if action == storage.Nothing && s.Snapshot().RebootRequired {
+		return storage.Reboot
+	}
+	return action
+}
+
+// Output returns the decoded output lines written by the step with the given
+// command id since offset.
+func (s *InstallSession) Output(id string, offset int) ([]OutputLine, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	step := s.step(id)
+	if step == nil {
+		return nil, errors.New("execute: id not found")
+	}
+	return step.output.Lines(offset), nil
+}
+
+// Wait blocks until the current session has finished. It returns immediately
+// if no session was started.
+func (s *InstallSession) Wait() {
+	s.mu.Lock()
+	done := s.done
+	s.mu.Unlock()
+
+	if done != nil {
+		<-done
+	}
+}
+
+// dispatch starts the pending steps allowed by the scheduling rules unless the
+// session is paused, and finishes the session once no step is left. Callers
+// must hold s.mu.
+func (s *InstallSession) dispatch() {
+	if !s.paused {
+		schedule(s.steps, s.maxConcurrency, func(step *sessionStep) {
+			ctx, cancel := context.WithCancel(context.Background())
+			step.Status, step.cancel, step.StartedAt = status.Running, cancel, time.Now()
+			go s.run(ctx, step)
+		})
+	}
+
+	hasPending := slices.ContainsFunc(s.steps, func(st *sessionStep) bool { return st.Status == status.Pending })
+	if s.hasRunning() || s.paused && hasPending {
+		return
+	}
+	// Pending steps left with nothing running wait on a dependency cycle
+	for _, step := range s.steps {
+		if step.Status == status.Pending {
+			step.Status = status.Skiped
+		}
+	}
+	s.finish()
+}
+
+func (s *InstallSession) run(ctx context.Context, step *sessionStep) {
+	runner := s.Runner
+	if runner == nil {
+		runner = ProcessRunner{}
+	}
+	name := step.Command.Name
+	if step.Command.Id != name {
+		name = step.Command.Id + "_" + name
+	}
+	ref, closeLog, err := s.Logs.capture(step.output, s.logRun, name)
+
+	result := runner.Run(ctx, step.Command, step.output)
+	result.LogFile = ref
+	err = errors.Join(err, closeLog())
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err != nil {
+		s.err = err
+	}
+	step.cancel()
+	result.Status = Classify(step.Command.Driver, result)
+	result.ExitCodeInfo = DescribeExitCode(step.Command.Driver, result.ExitCode)
+	step.Result, step.Status, step.FinishedAt = &result, result.Status, time.Now()
+	s.record(step)
+	s.publish("session:step", step.Step)
+	s.dispatch()
+	s.save()
+}
+
+// record adds the finished step to the history. Callers must hold s.mu.
+func (s *InstallSession) record(step *sessionStep) {
+	if s.History == nil {
+		return
+	}
+
+	tail := step.output.tail(historyTailLimit)
+	var verifyDetail string
+	if step.Result.Verification != nil {
+		verifyDetail = step.Result.Verification.Detail
+	}
+	cmdLine := step.Result.CommandLine
+	if cmdLine == "" {
+		cmdLine = commandLine(step.Command.Driver)
+	}
+
+	if err := s.History.AddStep(s.runId, storage.InstallStep{
+		DriverId:     step.Command.Driver.Id,
+		Name:         step.Command.Name,
+		GroupName:    step.Command.GroupName,
+		CommandLine:  cmdLine,
+		ExitCode:     step.Result.ExitCode,
+		Lapse:        step.Result.Lapse,
+		Status:       step.Status,
+		OutputTail:   tail,
+		LogFile:      step.Result.LogFile,
+		VerifyDetail: verifyDetail,
+		StartedAt:    step.StartedAt,
+		FinishedAt:   step.FinishedAt,
+	}); err != nil {
+		s.err = err
+	}
+}
+
+// step returns the step with the given command id, or nil if there is none.
+// Callers must hold s.mu.
+func (s *InstallSession) step(id string) *sessionStep {
+	if idx := slices.IndexFunc(s.steps, func(st *sessionStep) bool { return st.Command.Id == id }); idx != -1 {
+		return s.steps[idx]
+	}
+	return nil
+}
+
+// hasRunning reports whether any step has a live process. Callers must hold s.mu.
+func (s *InstallSession) hasRunning() bool {
+	return countRunning(s.steps, nil) > 0
+}
+
+// finish marks the session as done. Callers must hold s.mu.
+func (s *InstallSession) finish() {
+	if s.status != status.Running {
+		return
+	}
+
+	s.status = status.Completed
+	if s.aborted {
+		s.status = status.Aborted
+	} else if slices.ContainsFunc(s.steps, func(st *sessionStep) bool { return !succeeded(st.Status) }) {
+		s.status = status.Failed
+	}
+	if s.History != nil {
+		if err := s.History.FinishRun(s.runId, s.status, time.Now()); err != nil {
+			s.err = err
+		}
+	}
+	if err := s.discard(); err != nil {
+		s.err = err
+	}
+	s.publish("session:finished", s.snapshot())
+	close(s.done)
+}
+
+// publish publishes an event to Events. Callers must hold s.mu.
+func (s *InstallSession) publish(name string, data ...any) {
+	if s.Events != nil {
+		s.Events.Publish(name, data...)
+	}
+}
@@ -0,0 +1,648 @@
+package execute_test
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"install-it/pkg/event"
+	"install-it/pkg/execute"
+	"install-it/pkg/status"
+	"install-it/pkg/storage"
+)
+
+// fakeRunner blocks each command until it is released or cancelled, and
+// records which commands ran concurrently.
+type fakeRunner struct {
+	mu       sync.Mutex
+	running  map[string]bool
+	overlaps [][2]string
+	maxLive  int
+	started  chan string
+	release  map[string]chan execute.CommandResult
+}
+
+func newFakeRunner(ids ...string) *fakeRunner {
+	f := &fakeRunner{
+		running: map[string]bool{},
+		started: make(chan string, len(ids)),
+		release: map[string]chan execute.CommandResult{},
+	}
+	for _, id := range ids {
+		f.release[id] = make(chan execute.CommandResult, 1)
+	}
+	return f
+}
+
+func (f *fakeRunner) Run(ctx context.Context, cmd execute.PlannedCommand, output *execute.OutputLog) execute.CommandResult {
+	f.mu.Lock()
+	for other := range f.running {
+		f.overlaps = append(f.overlaps, [2]string{other, cmd.Id})
+	}
+	f.running[cmd.Id] = true
+	f.maxLive = max(f.maxLive, len(f.running))
+	f.mu.Unlock()
+
+	f.started <- cmd.Id
+
+	var result execute.CommandResult
+	select {
+	case result = <-f.release[cmd.Id]:
+	case <-ctx.Done():
+		result = execute.CommandResult{ExitCode: 1, Aborted: true}
+	}
+
+	f.mu.Lock()
+	delete(f.running, cmd.Id)
+	f.mu.Unlock()
+	return result
+}
+
+// finish releases the command with id using result.
+func (f *fakeRunner) finish(id string, result execute.CommandResult) {
+	f.release[id] <- result
+}
+
+// waitStarted waits until the commands with ids have been started, in any order.
+func waitStarted(t *testing.T, f *fakeRunner, ids ...string) {
+	t.Helper()
+	want := map[string]bool{}
+	for _, id := range ids {
+		want[id] = true
+	}
+	for range ids {
+		select {
+		case got := <-f.started:
+			if !want[got] {
+				t.Fatalf("started %q, want one of %v", got, ids)
+			}
+			delete(want, got)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for %v to start", ids)
+		}
+	}
+}
+
+// fakeHistory records the history of a session in memory.
+type fakeHistory struct {
+	mu        sync.Mutex
+	machine   string
+	steps     []storage.InstallStep
+	runStatus status.Status
+}
+
+func (f *fakeHistory) StartRun(machine string, startedAt time.Time) (uint, error) {
+	f.machine = machine
+	return 1, nil
+}
+
+func (f *fakeHistory) AddStep(runId uint, step storage.InstallStep) error {
+	f.mu.Lock()
+	f.steps = append(f.steps, step)
+	f.mu.Unlock()
+	return nil
+}
+
+func (f *fakeHistory) FinishRun(runId uint, runStatus status.Status, finishedAt time.Time) error {
+	f.runStatus = runStatus
+	return nil
+}
+
+func stepStatus(s *execute.InstallSession, id string) status.Status {
+	for _, step := range s.Snapshot().Steps {
+		if step.Command.Id == id {
+			return step.Status
+		}
+	}
+	return ""
+}
+
+func planned(id string, incompatibles ...string) execute.PlannedCommand {
+	return execute.PlannedCommand{Id: id, Name: id, Incompatibles: incompatibles}
+}
+
+// ==================== Scheduling ====================
+
+func TestInstallSession_Serial_RunsOneAtATime(t *testing.T) {
+	t.Parallel()
+
+	runner := newFakeRunner("a", "b", "c")
+	s := &execute.InstallSession{Runner: runner}
+	if err := s.Start(1, []execute.PlannedCommand{planned("a"), planned("b"), planned("c")}); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	for _, id := range []string{"a", "b", "c"} {
+		waitStarted(t, runner, id)
+		runner.finish(id, execute.CommandResult{Lapse: 1})
+	}
+	s.Wait()
+
+	if runner.maxLive != 1 {
+		t.Errorf("max concurrent commands: got %d, want 1", runner.maxLive)
+	}
+	if snap := s.Snapshot(); snap.Status != status.Completed {
+		t.Errorf("session status: got %q, want %q", snap.Status, status.Completed)
+	}
+}
+
+func TestInstallSession_Parallel_RespectsIncompatibles(t *testing.T) {
+	t.Parallel()
+
+	runner := newFakeRunner("a", "b", "c")
+	s := &execute.InstallSession{Runner: runner}
+	if err := s.Start(0, []execute.PlannedCommand{planned("a"), planned("b", "a"), planned("c")}); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	waitStarted(t, runner, "a", "c")
+	if got := stepStatus(s, "b"); got != status.Pending {
+		t.Fatalf("b should wait for a, got status %q", got)
+	}
+
+	runner.finish("a", execute.CommandResult{})
+	waitStarted(t, runner, "b")
+	runner.finish("b", execute.CommandResult{})
+	runner.finish("c", execute.CommandResult{})
+	s.Wait()
+
+	for _, pair := range runner.overlaps {
+		if pair == [2]string{"a", "b"} || pair == [2]string{"b", "a"} {
+			t.Errorf("incompatible commands a and b ran concurrently")
+		}
+	}
+}
+
+func TestInstallSession_MaxConcurrency(t *testing.T) {
+	t.Parallel()
+
+	runner := newFakeRunner("a", "b", "c", "d")
+	s := &execute.InstallSession{Runner: runner}
+	if err := s.Start(2, []execute.PlannedCommand{planned("a"), planned("b"), planned("c"), planned("d")}); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	waitStarted(t, runner, "a", "b")
+	runner.finish("a", execute.CommandResult{})
+	waitStarted(t, runner, "c")
+	runner.finish("b", execute.CommandResult{})
+	waitStarted(t, runner, "d")
+	runner.finish("c", execute.CommandResult{})
+	runner.finish("d", execute.CommandResult{})
+	s.Wait()
+
+	if runner.maxLive != 2 {
+		t.Errorf("max concurrent commands: got %d, want 2", runner.maxLive)
+	}
+}
+
+func TestInstallSession_GroupLimitAndLocks(t *testing.T) {
+	t.Parallel()
+
+	runner := newFakeRunner("g1", "g2", "msi1", "msi2", "free")
+	s := &execute.InstallSession{Runner: runner}
+	cmds := []execute.PlannedCommand{
+		{Id: "g1", GroupId: 1, GroupLimit: 1},
+		{Id: "g2", GroupId: 1, GroupLimit: 1},
+		{Id: "msi1", Locks: []string{"msi"}},
+		{Id: "msi2", Locks: []string{"msi", "disk"}},
+		{Id: "free", Locks: []string{"disk"}},
+	}
+	if err := s.Start(0, cmds); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	waitStarted(t, runner, "g1", "msi1", "free")
+	for _, id := range []string{"g2", "msi2"} {
+		if got := stepStatus(s, id); got != status.Pending {
+			t.Errorf("%s should wait, got status %q", id, got)
+		}
+	}
+
+	runner.finish("g1", execute.CommandResult{})
+	waitStarted(t, runner, "g2")
+	runner.finish("msi1", execute.CommandResult{})
+	if got := stepStatus(s, "msi2"); got != status.Pending {
+		t.Errorf("msi2 should wait for the disk lock, got status %q", got)
+	}
+	runner.finish("free", execute.CommandResult{})
+	waitStarted(t, runner, "msi2")
+	runner.finish("g2", execute.CommandResult{})
+	runner.finish("msi2", execute.CommandResult{})
+	s.Wait()
+}
+
+func TestInstallSession_WaitsForDependencies(t *testing.T) {
+	t.Parallel()
+
+	runner := newFakeRunner("chipset", "audio", "lan")
+	s := &execute.InstallSession{Runner: runner}
+	audio := planned("audio")
+	audio.DependsOn = []string{"chipset"}
+	if err := s.Start(0, []execute.PlannedCommand{audio, planned("chipset"), planned("lan")}); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	waitStarted(t, runner, "chipset", "lan")
+	if got := stepStatus(s, "audio"); got != status.Pending {
+		t.Fatalf("audio should wait for chipset, got status %q", got)
+	}
+
+	runner.finish("chipset", execute.CommandResult{Lapse: 1, ExitCode: 3010})
+	waitStarted(t, runner, "audio")
+	runner.finish("audio", execute.CommandResult{Lapse: 1})
+	runner.finish("lan", execute.CommandResult{Lapse: 1})
+	s.Wait()
+
+	if snap := s.Snapshot(); snap.Status != status.Completed {
+		t.Errorf("session status: got %q, want %q", snap.Status, status.Completed)
+	}
+}
+
+func TestInstallSession_SkipsDependentsOfFailures(t *testing.T) {
+	t.Parallel()
+
+	runner := newFakeRunner("a", "b", "c", "d")
+	s := &execute.InstallSession{Runner: runner}
+	b, c := planned("b"), planned("c")
+	b.DependsOn, c.DependsOn = []string{"a"}, []string{"b"}
+	if err := s.Start(1, []execute.PlannedCommand{planned("a"), b, c, planned("d")}); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	waitStarted(t, runner, "a")
+	runner.finish("a", execute.CommandResult{Lapse: 1, ExitCode: 1})
+	waitStarted(t, runner, "d")
+	runner.finish("d", execute.CommandResult{Lapse: 1})
+	s.Wait()
+
+	want := map[string]status.Status{"a": status.Failed, "b": status.Skiped, "c": status.Skiped, "d": status.Completed}
+	for id, w := range want {
+		if got := stepStatus(s, id); got != w {
+			t.Errorf("%s: got %q, want %q", id, got, w)
+		}
+	}
+}
+
+func TestInstallSession_SkipsDependencyCycle(t *testing.T) {
+	t.Parallel()
+
+	s := &execute.InstallSession{Runner: newFakeRunner("a", "b")}
+	a, b := planned("a"), planned("b")
+	a.DependsOn, b.DependsOn = []string{"b"}, []string{"a"}
+	if err := s.Start(0, []execute.PlannedCommand{a, b}); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	s.Wait()
+
+	if got := stepStatus(s, "a"); got != status.Skiped {
+		t.Errorf("a: got %q, want %q", got, status.Skiped)
+	}
+	if snap := s.Snapshot(); snap.Status != status.Failed {
+		t.Errorf("session status: got %q, want %q", snap.Status, status.Failed)
+	}
+}
+
+func TestInstallSession_ClassifiesResults(t *testing.T) {
+	t.Parallel()
+
+	runner := newFakeRunner("ok", "allowed", "failed", "speeded")
+	s := &execute.InstallSession{Runner: runner}
+	cmds := []execute.PlannedCommand{
+		{Id: "ok"},
+		{Id: "allowed", Driver: storage.Driver{AllowRtCodes: []int32{2}}},
+		{Id: "failed"},
+		{Id: "speeded", Driver: storage.Driver{MinExeTime: 5}},
+	}
+	if err := s.Start(1, cmds); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	results := map[string]execute.CommandResult{
+		"ok":      {Lapse: 1},
+		"allowed": {Lapse: 1, ExitCode: 2},
+		"failed":  {Lapse: 1, ExitCode: 1},
+		"speeded": {Lapse: 1},
+	}
+	for _, cmd := range cmds {
+		waitStarted(t, runner, cmd.Id)
+		runner.finish(cmd.Id, results[cmd.Id])
+	}
+	s.Wait()
+
+	want := map[string]status.Status{
+		"ok":      status.Completed,
+		"allowed": status.Completed,
+		"failed":  status.Failed,
+		"speeded": status.Speeded,
+	}
+	for id, w := range want {
+		if got := stepStatus(s, id); got != w {
+			t.Errorf("%s: got %q, want %q", id, got, w)
+		}
+	}
+	if snap := s.Snapshot(); snap.Status != status.Failed {
+		t.Errorf("session status: got %q, want %q", snap.Status, status.Failed)
+	}
+}
+
+func TestInstallSession_RebootRequired(t *testing.T) {
+	t.Parallel()
+
+	runner := newFakeRunner("a", "b")
+	s := &execute.InstallSession{Runner: runner}
+	if err := s.Start(1, []execute.PlannedCommand{planned("a"), planned("b")}); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	waitStarted(t, runner, "a")
+	runner.finish("a", execute.CommandResult{Lapse: 1})
+	waitStarted(t, runner, "b")
+	if s.SuccessAction(storage.Nothing) != storage.Nothing {
+		t.Error("no reboot should be required before any step needs it")
+	}
+	runner.finish("b", execute.CommandResult{Lapse: 1, ExitCode: 3010})
+	s.Wait()
+
+	snap := s.Snapshot()
+	if got := stepStatus(s, "b"); got != status.RebootRequired {
+		t.Errorf("b: got %q, want %q", got, status.RebootRequired)
+	}
+	if snap.Status != status.Completed {
+		t.Errorf("session status: got %q, want %q", snap.Status, status.Completed)
+	}
+	if !snap.RebootRequired {
+		t.Error("snapshot should require a reboot")
+	}
+
+	tests := []struct {
+		chosen, want storage.SuccessAction
+	}{
+		{storage.Nothing, storage.Reboot},
+		{storage.Reboot, storage.Reboot},
+		{storage.Shutdown, storage.Shutdown},
+		{storage.Firmware, storage.Firmware},
+	}
+	for _, tt := range tests {
+		if got := s.SuccessAction(tt.chosen); got != tt.want {
+			t.Errorf("SuccessAction(%q): got %q, want %q", tt.chosen, got, tt.want)
+		}
+	}
+}
+
+// ==================== Start / Abort ====================
+
+func TestInstallSession_Start_RejectsWhileRunning(t *testing.T) {
+	t.Parallel()
+
+	runner := newFakeRunner("a")
+	s := &execute.InstallSession{Runner: runner}
+	if err := s.Start(1, []execute.PlannedCommand{planned("a")}); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	waitStarted(t, runner, "a")
+
+	if err := s.Start(1, []execute.PlannedCommand{planned("a")}); err == nil {
+		t.Error("expected error when starting a second session, got nil")
+	}
+
+	runner.finish("a", execute.CommandResult{})
+	s.Wait()
+}
+
+func TestInstallSession_Start_EmptyPlan(t *testing.T) {
+	t.Parallel()
+
+	var s execute.InstallSession
+	if err := s.Start(0, nil); err == nil {
+		t.Error("expected error for an empty plan, got nil")
+	}
+	if snap := s.Snapshot(); snap.Status != status.Pending {
+		t.Errorf("idle session status: got %q, want %q", snap.Status, status.Pending)
+	}
+}
+
+func TestInstallSession_Abort_RunningAndPending(t *testing.T) {
+	t.Parallel()
+
+	runner := newFakeRunner("a", "b")
+	s := &execute.InstallSession{Runner: runner}
+	if err := s.Start(1, []execute.PlannedCommand{planned("a"), planned("b")}); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	waitStarted(t, runner, "a")
+
+	if err := s.Abort("b"); err != nil {
+		t.Fatalf("Abort pending: %v", err)
+	}
+	if err := s.Abort("a"); err != nil {
+		t.Fatalf("Abort running: %v", err)
+	}
+	s.Wait()
+
+	if got := stepStatus(s, "a"); got != status.Aborted {
+		t.Errorf("a: got %q, want %q", got, status.Aborted)
+	}
+	if got := stepStatus(s, "b"); got != status.Aborted {
+		t.Errorf("b: got %q, want %q", got, status.Aborted)
+	}
+	if err := s.Abort("a"); err == nil {
+		t.Error("expected error when aborting a finished step, got nil")
+	}
+	if err := s.Abort("nonexistent_id"); err == nil {
+		t.Error("expected error when aborting non-existent id, got nil")
+	}
+}
+
+func TestInstallSession_PauseAndContinue(t *testing.T) {
+	t.Parallel()
+
+	runner := newFakeRunner("a", "b")
+	s := &execute.InstallSession{Runner: runner}
+	if err := s.Start(1, []execute.PlannedCommand{planned("a"), planned("b")}); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	waitStarted(t, runner, "a")
+
+	if err := s.Pause(); err != nil {
+		t.Fatalf("Pause: %v", err)
+	}
+	runner.finish("a", execute.CommandResult{})
+	for stepStatus(s, "a") == status.Running {
+		time.Sleep(time.Millisecond)
+	}
+	if snap := s.Snapshot(); !snap.Paused || snap.Status != status.Running || stepStatus(s, "b") != status.Pending {
+		t.Fatalf("paused session should hold b: %+v", snap)
+	}
+
+	if err := s.Continue(); err != nil {
+		t.Fatalf("Continue: %v", err)
+	}
+	waitStarted(t, runner, "b")
+	runner.finish("b", execute.CommandResult{})
+	s.Wait()
+
+	if snap := s.Snapshot(); snap.Paused || snap.Status != status.Completed {
+		t.Errorf("unexpected final state: %+v", snap)
+	}
+	if err := s.Pause(); err == nil {
+		t.Error("expected error when pausing a finished session, got nil")
+	}
+}
+
+func TestInstallSession_AbortAll(t *testing.T) {
+	t.Parallel()
+
+	runner := newFakeRunner("a", "b", "c")
+	history := &fakeHistory{}
+	s := &execute.InstallSession{Runner: runner, History: history}
+	if err := s.Start(2, []execute.PlannedCommand{planned("a"), planned("b"), planned("c")}); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	waitStarted(t, runner, "a", "b")
+	runner.finish("a", execute.CommandResult{})
+	waitStarted(t, runner, "c")
+
+	if err := s.Pause(); err != nil {
+		t.Fatalf("Pause: %v", err)
+	}
+	if err := s.AbortAll(); err != nil {
+		t.Fatalf("AbortAll: %v", err)
+	}
+	s.Wait()
+
+	want := map[string]status.Status{"a": status.Completed, "b": status.Aborted, "c": status.Aborted}
+	for id, w := range want {
+		if got := stepStatus(s, id); got != w {
+			t.Errorf("%s: got %q, want %q", id, got, w)
+		}
+	}
+	if snap := s.Snapshot(); snap.Status != status.Aborted || snap.Paused {
+		t.Errorf("unexpected final state: %+v", snap)
+	}
+	if history.runStatus != status.Aborted {
+		t.Errorf("run status: got %q, want %q", history.runStatus, status.Aborted)
+	}
+}
+
+func TestInstallSession_AbortAll_SkipsPending(t *testing.T) {
+	t.Parallel()
+
+	runner := newFakeRunner("a", "b")
+	s := &execute.InstallSession{Runner: runner}
+	if err := s.Start(1, []execute.PlannedCommand{planned("a"), planned("b")}); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	waitStarted(t, runner, "a")
+
+	if err := s.AbortAll(); err != nil {
+		t.Fatalf("AbortAll: %v", err)
+	}
+	s.Wait()
+
+	if got := stepStatus(s, "b"); got != status.Skiped {
+		t.Errorf("b: got %q, want %q", got, status.Skiped)
+	}
+	if err := s.AbortAll(); err == nil {
+		t.Error("expected error when aborting a finished session, got nil")
+	}
+}
+
+// ==================== Events ====================
+
+func TestInstallSession_PublishesEvents(t *testing.T) {
+	t.Parallel()
+
+	runner := newFakeRunner("a", "b")
+	events := event.NewChannelPublisher(3)
+	s := &execute.InstallSession{Runner: runner, Events: events}
+	if err := s.Start(1, []execute.PlannedCommand{planned("a"), planned("b")}); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	waitStarted(t, runner, "a")
+	if err := s.AbortAll(); err != nil {
+		t.Fatalf("AbortAll: %v", err)
+	}
+	s.Wait()
+
+	step := <-events.C
+	if step.Name != "session:step" || step.Data[0].(execute.Step).Status != status.Aborted {
+		t.Errorf("unexpected step event: %+v", step)
+	}
+	finished := <-events.C
+	snap := finished.Data[0].(execute.SessionSnapshot)
+	if finished.Name != "session:finished" || snap.Status != status.Aborted || snap.Steps[1].Status != status.Skiped {
+		t.Errorf("unexpected finished event: %+v", finished)
+	}
+}
+
+// ==================== History ====================
+
+func TestInstallSession_RecordsHistory(t *testing.T) {
+	t.Parallel()
+
+	runner := newFakeRunner("1", "2")
+	history := &fakeHistory{}
+	s := &execute.InstallSession{Runner: runner, History: history}
+	cmds := []execute.PlannedCommand{
+		{Id: "1", Name: "Audio", GroupName: "Realtek", Driver: storage.Driver{Id: 1, Path: "setup.exe", Flags: []string{"/s", "/log path"}}},
+		{Id: "2", Driver: storage.Driver{Id: 2}},
+	}
+	if err := s.Start(1, cmds); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	waitStarted(t, runner, "1")
+	runner.finish("1", execute.CommandResult{Lapse: 2})
+	waitStarted(t, runner, "2")
+	runner.finish("2", execute.CommandResult{Lapse: 2, ExitCode: 1603})
+	s.Wait()
+
+	if history.machine == "" {
+		t.Error("run should be recorded with the machine name")
+	}
+	if len(history.steps) != 2 {
+		t.Fatalf("expected 2 recorded steps, got %d", len(history.steps))
+	}
+	if step := history.steps[0]; step.DriverId != 1 || step.GroupName != "Realtek" || step.CommandLine != `setup.exe /s "/log path"` {
+		t.Errorf("unexpected first step: %+v", step)
+	}
+	if step := history.steps[1]; step.ExitCode != 1603 || step.Status != status.Failed {
+		t.Errorf("unexpected second step: %+v", step)
+	}
+	if info := s.Snapshot().Steps[1].Result.ExitCodeInfo; info.Name != "ERROR_INSTALL_FAILURE" {
+		t.Errorf("exit code not described: %+v", info)
+	}
+	if history.runStatus != status.Failed {
+		t.Errorf("run status: got %q, want %q", history.runStatus, status.Failed)
+	}
+}
+
+func TestInstallSession_KeepsLogs(t *testing.T) {
+	t.Parallel()
+
+	runner := newFakeRunner("1")
+	history := &fakeHistory{}
+	logs := &execute.LogStore{Dir: t.TempDir()}
+	s := &execute.InstallSession{Runner: runner, History: history, Logs: logs}
+	if err := s.Start(1, []execute.PlannedCommand{{Id: "1", Name: "Audio"}}); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	waitStarted(t, runner, "1")
+	runner.finish("1", execute.CommandResult{})
+	s.Wait()
+
+	ref := s.Snapshot().Steps[0].Result.LogFile
+	if !strings.HasSuffix(ref, "/1_Audio.log") {
+		t.Errorf("unexpected log reference %q", ref)
+	}
+	if _, err := logs.Read(ref, 0, 0); err != nil {
+		t.Errorf("Read: %v", err)
+	}
+	if len(history.steps) != 1 || history.steps[0].LogFile != ref {
+		t.Errorf("log reference not recorded: %+v", history.steps)
+	}
+}
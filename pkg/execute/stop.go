@@ -0,0 +1,102 @@
+package execute
+
+import (
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+const (
+	// stopGracePeriod is the time the processes of a stopped command get to
+	// exit by themselves before they are killed.
+	stopGracePeriod = 5 * time.Second
+	// killWait is the time killed processes get to disappear before they are
+	// reported as survivors.
+	killWait = 2 * time.Second
+	// exitPollInterval is the interval stopped processes are checked at.
+	exitPollInterval = 100 * time.Millisecond
+)
+
+// processTree returns root and all its descendants, parents before their
+// children. Processes started before their parent are left out, since Windows
+// keeps the parent pid of orphans, which may have been reused by now.
+func processTree(root *process.Process) []*process.Process {
+	tree := []*process.Process{root}
+	seen := map[int32]bool{root.Pid: true}
+	for i := 0; i < len(tree); i++ {
+		parent := tree[i]
+		children, err := children(parent)
+		if err != nil {
+			continue
+		}
+		parentCreated, parentErr := parent.CreateTime()
+		for _, child := range children {
+			if seen[child.Pid] {
+				continue
+			}
+			if created, err := child.CreateTime(); parentErr == nil && err == nil && created < parentCreated {
+				continue
+			}
+			seen[child.Pid] = true
+			tree = append(tree, child)
+		}
+	}
+	return tree
+}
+
+// stopTree asks every process of tree to exit, the deepest descendants first,
+// and kills those still running after grace. Processes that cannot be asked
+// are killed right away. It returns the pids of the processes still running
+// after being killed.
+func stopTree(tree []*process.Process, grace time.Duration) ([]int32, error) {
+	var asked, forced []*process.Process
+	for _, p := range slices.Backward(tree) {
+		if err := terminate(p); err != nil {
+			forced = append(forced, p)
+		} else {
+			asked = append(asked, p)
+		}
+	}
+
+	var errs error
+	remaining := append(forced, waitExit(asked, grace)...)
+	for _, p := range remaining {
+		if !running(p) {
+			continue
+		}
+		if err := p.Kill(); err != nil && running(p) {
+			errs = errors.Join(errs, err)
+		}
+	}
+
+	var survivors []int32
+	for _, p := range waitExit(remaining, killWait) {
+		survivors = append(survivors, p.Pid)
+	}
+	return survivors, errs
+}
+
+// waitExit waits up to timeout for procs to exit, and returns those still
+// running.
+func waitExit(procs []*process.Process, timeout time.Duration) []*process.Process {
+	deadline := time.Now().Add(timeout)
+	for {
+		procs = slices.DeleteFunc(procs, func(p *process.Process) bool { return !running(p) })
+		if len(procs) == 0 || time.Now().After(deadline) {
+			return procs
+		}
+		time.Sleep(exitPollInterval)
+	}
+}
+
+// running reports whether p is still running. Zombies, which have exited but
+// were not reaped by their parent yet, are not.
+func running(p *process.Process) bool {
+	if ok, err := p.IsRunning(); err != nil || !ok {
+		return false
+	}
+	status, err := p.Status()
+	return err != nil || !slices.Contains(status, process.Zombie)
+}
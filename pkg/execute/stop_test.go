@@ -0,0 +1,72 @@
+package execute
+
+import (
+	"bufio"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+// TestStopHelperProcess is run as a child process by the stop tests. It
+// ignores requests to exit, so that it has to be killed.
+func TestStopHelperProcess(t *testing.T) {
+	if os.Getenv("INSTALL_IT_STOP_HELPER") != "1" {
+		t.Skip("helper process")
+	}
+	signal.Ignore(syscall.SIGTERM)
+	os.Stdout.WriteString("ready\n")
+	time.Sleep(time.Minute)
+}
+
+func TestStopTree_KillsAfterGracePeriod(t *testing.T) {
+	child := exec.Command(os.Args[0], "-test.run=^TestStopHelperProcess$")
+	child.Env = append(os.Environ(), "INSTALL_IT_STOP_HELPER=1")
+	stdout, err := child.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := child.Start(); err != nil {
+		t.Fatalf("start helper: %v", err)
+	}
+	exited := make(chan struct{})
+	go func() {
+		child.Wait()
+		close(exited)
+	}()
+	t.Cleanup(func() {
+		child.Process.Kill()
+		<-exited
+	})
+	if _, err := bufio.NewReader(stdout).ReadString('\n'); err != nil {
+		t.Fatalf("helper did not get ready: %v", err)
+	}
+
+	proc, err := process.NewProcess(int32(child.Process.Pid))
+	if err != nil {
+		t.Fatal(err)
+	}
+	survivors, err := stopTree(processTree(proc), 200*time.Millisecond)
+	if err != nil || len(survivors) != 0 {
+		t.Errorf("got survivors %v, %v", survivors, err)
+	}
+	select {
+	case <-exited:
+	case <-time.After(5 * time.Second):
+		t.Error("helper is still running")
+	}
+}
+
+func TestRunning_ExitedProcess(t *testing.T) {
+	child := exec.Command(os.Args[0], "-test.run=^$")
+	if err := child.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if running(&process.Process{Pid: int32(child.Process.Pid)}) {
+		t.Error("an exited process should not be running")
+	}
+}
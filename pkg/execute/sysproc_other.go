@@ -0,0 +1,16 @@
+//go:build !windows
+
+package execute
+
+import (
+	"install-it/pkg/storage"
+	"syscall"
+)
+
+func sysProcAttr(driver storage.Driver) *syscall.SysProcAttr {
+	return nil
+}
+
+func withoutWindow(attr *syscall.SysProcAttr) *syscall.SysProcAttr {
+	return attr
+}
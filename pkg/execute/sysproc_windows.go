@@ -0,0 +1,28 @@
+//go:build windows
+
+package execute
+
+import (
+	"install-it/pkg/storage"
+	"syscall"
+)
+
+// sysProcAttr returns the attributes of the process running driver, which set
+// the command line of drivers run through cmd, see cmdExeLine.
+func sysProcAttr(driver storage.Driver) *syscall.SysProcAttr {
+	if line, ok := cmdExeLine(driver); ok {
+		return &syscall.SysProcAttr{CmdLine: line}
+	}
+	return nil
+}
+
+// withoutWindow returns attr, or new attributes if it is nil, starting the
+// process without a console window.
+func withoutWindow(attr *syscall.SysProcAttr) *syscall.SysProcAttr {
+	if attr == nil {
+		attr = &syscall.SysProcAttr{}
+	}
+	attr.HideWindow = true
+	attr.CreationFlags |= 0x08000000 // CREATE_NO_WINDOW
+	return attr
+}
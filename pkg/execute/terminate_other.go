@@ -0,0 +1,10 @@
+//go:build !windows
+
+package execute
+
+import "github.com/shirou/gopsutil/v3/process"
+
+// terminate asks p to exit with SIGTERM.
+func terminate(p *process.Process) error {
+	return p.Terminate()
+}
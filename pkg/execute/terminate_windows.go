@@ -0,0 +1,18 @@
+//go:build windows
+
+package execute
+
+import (
+	"os/exec"
+	"strconv"
+
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+// terminate asks p to exit by closing its windows, which lets installers roll
+// back. It fails for processes without windows, such as console programs.
+func terminate(p *process.Process) error {
+	cmd := exec.Command("taskkill", "/PID", strconv.Itoa(int(p.Pid)))
+	cmd.SysProcAttr = withoutWindow(nil)
+	return cmd.Run()
+}
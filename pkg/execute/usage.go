@@ -0,0 +1,85 @@
+package execute
+
+import (
+	"os"
+	"slices"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+// sampleInterval is the interval the process tree of a running command is
+// sampled at.
+const sampleInterval = time.Second
+
+// ResourceUsage is the resource usage of the process tree of a command.
+type ResourceUsage struct {
+	PeakRss    uint64   `json:"peakRss"`    // Highest sampled resident set size of the tree in bytes
+	CpuTime    float32  `json:"cpuTime"`    // User and system CPU time of the tree in seconds
+	ChildCount int      `json:"childCount"` // Child processes seen, including grandchildren
+	ChildNames []string `json:"childNames"` // Distinct executable names of the child processes
+}
+
+// usageSampler accumulates the resource usage of a process tree from
+// periodic samples. Processes exiting between samples are only accounted for
+// up to their last sample.
+type usageSampler struct {
+	peakRss  uint64
+	cpu      map[int32]float64 // Last sampled CPU time by pid
+	children map[int32]string  // Names of the child processes by pid
+}
+
+// sample records the usage of the process with pid and its descendants.
+func (u *usageSampler) sample(pid int32) {
+	root, err := process.NewProcess(pid)
+	if err != nil {
+		return
+	}
+	if u.cpu == nil {
+		u.cpu, u.children = make(map[int32]float64), make(map[int32]string)
+	}
+
+	var rss uint64
+	for _, p := range processTree(root) {
+		if mem, err := p.MemoryInfo(); err == nil {
+			rss += mem.RSS
+		}
+		if times, err := p.Times(); err == nil {
+			u.cpu[p.Pid] = times.User + times.System
+		}
+		if _, ok := u.children[p.Pid]; p != root && !ok {
+			u.children[p.Pid], _ = p.Name()
+		}
+	}
+	u.peakRss = max(u.peakRss, rss)
+}
+
+// usage returns the accumulated usage of the tree rooted at the process with
+// pid. The CPU time of the root is taken from state once it has exited.
+func (u *usageSampler) usage(pid int32, state *os.ProcessState) ResourceUsage {
+	var cpu float64
+	for p, seconds := range u.cpu {
+		if p != pid {
+			cpu += seconds
+		}
+	}
+	rootCpu := u.cpu[pid]
+	if state != nil {
+		rootCpu = max(rootCpu, (state.UserTime() + state.SystemTime()).Seconds())
+	}
+
+	names := []string{}
+	for _, name := range u.children {
+		if name != "" && !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+
+	return ResourceUsage{
+		PeakRss:    u.peakRss,
+		CpuTime:    float32(cpu + rootCpu),
+		ChildCount: len(u.children),
+		ChildNames: names,
+	}
+}
@@ -0,0 +1,54 @@
+package execute
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestUsageHelperProcess is run as a child process by the usage tests.
+func TestUsageHelperProcess(t *testing.T) {
+	if os.Getenv("INSTALL_IT_USAGE_HELPER") != "1" {
+		t.Skip("helper process")
+	}
+	time.Sleep(10 * time.Second)
+}
+
+func TestUsageSampler_SamplesTree(t *testing.T) {
+	child := exec.Command(os.Args[0], "-test.run=^TestUsageHelperProcess$")
+	child.Env = append(os.Environ(), "INSTALL_IT_USAGE_HELPER=1")
+	if err := child.Start(); err != nil {
+		t.Fatalf("start helper: %v", err)
+	}
+	t.Cleanup(func() {
+		child.Process.Kill()
+		child.Wait()
+	})
+
+	var u usageSampler
+	u.sample(int32(os.Getpid()))
+	usage := u.usage(int32(os.Getpid()), nil)
+
+	if usage.PeakRss == 0 {
+		t.Error("peak RSS should be sampled")
+	}
+	if usage.ChildCount < 1 {
+		t.Fatalf("child count: got %d, want at least 1", usage.ChildCount)
+	}
+	name := strings.TrimSuffix(filepath.Base(os.Args[0]), ".exe")
+	if !slices.ContainsFunc(usage.ChildNames, func(n string) bool { return strings.HasPrefix(n, name[:min(len(name), 15)]) }) {
+		t.Errorf("child names %v should contain %q", usage.ChildNames, name)
+	}
+}
+
+func TestUsageSampler_ExitedProcess(t *testing.T) {
+	var u usageSampler
+	u.sample(-1)
+	if usage := u.usage(-1, nil); usage.PeakRss != 0 || usage.ChildCount != 0 || usage.ChildNames == nil {
+		t.Errorf("unexpected usage of a nonexistent process: %+v", usage)
+	}
+}
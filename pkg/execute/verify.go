@@ -0,0 +1,73 @@
+package execute
+
+import (
+	"context"
+	"fmt"
+	"install-it/pkg/status"
+	"install-it/pkg/storage"
+	"os"
+	"regexp"
+)
+
+// verifyTimeout is the number of seconds a verification command may run.
+const verifyTimeout = 60
+
+// Verification is the outcome of the verification check of a driver.
+type Verification struct {
+	Verified bool   `json:"verified"`
+	Detail   string `json:"detail"` // What was checked, and why it failed
+}
+
+// verify runs the verification check of driver, returning nil if it has none.
+// Cancelling ctx stops a running verification command.
+func verify(ctx context.Context, driver storage.Driver) *Verification {
+	check := driver.Verify
+	switch check.Type {
+	case storage.VerifyNone:
+		return nil
+
+	case storage.VerifyFile:
+		if _, err := os.Stat(check.Path); err != nil {
+			return &Verification{Detail: fmt.Sprintf("%s does not exist", check.Path)}
+		}
+		return &Verification{Verified: true, Detail: fmt.Sprintf("%s exists", check.Path)}
+
+	case storage.VerifyCommand, storage.VerifyOutput:
+		var re *regexp.Regexp
+		if check.Type == storage.VerifyOutput {
+			var err error
+			if re, err = regexp.Compile(check.Pattern); err != nil {
+				return &Verification{Detail: fmt.Sprintf("invalid pattern %q: %v", check.Pattern, err)}
+			}
+		}
+
+		command := newCommand(storage.Driver{
+			Path:    check.Path,
+			Flags:   check.Flags,
+			WorkDir: driver.WorkDir,
+			Env:     driver.Env,
+			Timeout: verifyTimeout,
+		}, &OutputLog{})
+		command.cmd.SysProcAttr = withoutWindow(command.cmd.SysProcAttr)
+		result := command.result(command.supervise(ctx))
+		cmdLine := commandLine(command.driver)
+
+		switch {
+		case result.Status == status.TimedOut, result.Status == status.Aborted:
+			return &Verification{Detail: fmt.Sprintf("%s did not finish: %s", cmdLine, result.Error)}
+		case re != nil && re.MatchString(result.Stdout+"\n"+result.Stderr):
+			return &Verification{Verified: true, Detail: fmt.Sprintf("output of %s matches %q", cmdLine, check.Pattern)}
+		case re != nil:
+			return &Verification{Detail: fmt.Sprintf("output of %s does not match %q", cmdLine, check.Pattern)}
+		case result.ExitCode == 0 && result.Error == "":
+			return &Verification{Verified: true, Detail: fmt.Sprintf("%s exited with 0", cmdLine)}
+		case result.ExitCode == -1:
+			return &Verification{Detail: fmt.Sprintf("%s could not run: %s", cmdLine, result.Error)}
+		default:
+			return &Verification{Detail: fmt.Sprintf("%s exited with %d", cmdLine, result.ExitCode)}
+		}
+
+	default:
+		return &Verification{Detail: fmt.Sprintf("unknown verification type %q", check.Type)}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"archive/zip"
 	"errors"
 	"fmt"
+	"install-it/pkg/archive"
 	"io"
 	"net/http"
 	"os"
@@ -180,41 +181,15 @@ func fromZip(j *job, orig string, dest string, opts ImportOptions) (err error) {
 			return j.ctx.Err()
 		}
 
-		zfreader, err := zf.Open()
-		if err != nil {
-			return fmt.Errorf("porter: cannot open zip entry %s: %w", zf.Name, err)
-		}
-		defer zfreader.Close()
-
-		// Use forward-slash name consistently
-		name := filepath.ToSlash(zf.Name)
-		extractPath := filepath.Join(dest, name)
-
 		// ZipSlip protection
-		cleanDest := filepath.Clean(dest)
-		if !strings.HasPrefix(filepath.Clean(extractPath), cleanDest+string(os.PathSeparator)) && filepath.Clean(extractPath) != cleanDest {
-			return fmt.Errorf("porter: illegal file path: %s", extractPath)
+		if _, err := archive.Target(dest, zf.Name); err != nil {
+			return fmt.Errorf("porter: %w", err)
 		}
 
-		j.msg(fmt.Sprintf("Extracting: %s", name))
-
-		if zf.FileInfo().IsDir() {
-			return os.MkdirAll(extractPath, zf.Mode())
-		}
+		j.msg(fmt.Sprintf("Extracting: %s", filepath.ToSlash(zf.Name)))
 
-		if err := os.MkdirAll(filepath.Dir(extractPath), os.ModePerm); err != nil {
-			return fmt.Errorf("porter: cannot create directory %s: %w", filepath.Dir(extractPath), err)
-		}
-
-		outFile, err := os.OpenFile(extractPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, zf.Mode())
-		if err != nil {
-			return fmt.Errorf("porter: cannot create file %s: %w", extractPath, err)
-		}
-		defer outFile.Close()
-
-		_, err = io.Copy(outFile, zfreader)
-		if err != nil {
-			return fmt.Errorf("porter: error writing file %s: %w", extractPath, err)
+		if _, err := archive.ExtractFile(zf, dest); err != nil {
+			return fmt.Errorf("porter: %w", err)
 		}
 
 		extracted += zf.FileInfo().Size()
@@ -12,4 +12,9 @@ const (
 	Skiped    Status = "skiped"
 	Speeded   Status = "speeded"
 	Errored   Status = "errored"
+	TimedOut  Status = "timedOut"
+	// Completed, but the changes take effect after a reboot
+	RebootRequired Status = "rebootRequired"
+	// Completed, but the verification check of the driver failed
+	Unverified Status = "unverified"
 )
@@ -11,6 +11,7 @@ type AppSetting struct {
 	SetPassword        bool          `json:"set_password"`
 	Password           string        `json:"password"`
 	ParallelInstall    bool          `json:"parallel_install"`
+	MaxConcurrency     int           `json:"max_concurrency"` // Installers running at once when ParallelInstall, 0 for no limit
 	SuccessAction      SuccessAction `json:"success_action"`
 	SuccessActionDelay int           `json:"success_action_delay"`
 	FilterMiniportNic  bool          `json:"filter_miniport_nic"`
@@ -22,6 +23,14 @@ type AppSetting struct {
 	AllowPreRelease    bool          `json:"allow_pre_release"`
 }
 
+// Concurrency returns the number of installers to run at once, 0 for no limit.
+func (s AppSetting) Concurrency() int {
+	if !s.ParallelInstall {
+		return 1
+	}
+	return max(s.MaxConcurrency, 0)
+}
+
 type SuccessAction string
 
 const (
@@ -46,6 +55,7 @@ func (s *AppSettingStorage) All() (AppSetting, error) {
 				FilterMicrosoftNic: true,
 				Language:           "en",
 				ParallelInstall:    true,
+				MaxConcurrency:     4,
 				SuccessAction:      Nothing,
 				SuccessActionDelay: 5,
 			}
@@ -32,6 +32,9 @@ func TestAppSettingStorage_AllWithoutExistingFile(t *testing.T) {
 	if result.ParallelInstall != true {
 		t.Errorf("expected ParallelInstall to be true, got %v", result.ParallelInstall)
 	}
+	if result.MaxConcurrency != 4 {
+		t.Errorf("expected MaxConcurrency to be 4, got %d", result.MaxConcurrency)
+	}
 	if result.SuccessAction != Nothing {
 		t.Errorf("expected SuccessAction to be 'nothing', got %s", result.SuccessAction)
 	}
@@ -235,3 +238,20 @@ func jsonMarshal(v any) []byte {
 	}
 	return b
 }
+
+func TestAppSetting_Concurrency(t *testing.T) {
+	tests := []struct {
+		setting AppSetting
+		want    int
+	}{
+		{AppSetting{ParallelInstall: false, MaxConcurrency: 4}, 1},
+		{AppSetting{ParallelInstall: true, MaxConcurrency: 4}, 4},
+		{AppSetting{ParallelInstall: true}, 0},
+		{AppSetting{ParallelInstall: true, MaxConcurrency: -1}, 0},
+	}
+	for _, tt := range tests {
+		if got := tt.setting.Concurrency(); got != tt.want {
+			t.Errorf("%+v: got %d, want %d", tt.setting, got, tt.want)
+		}
+	}
+}
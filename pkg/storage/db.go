@@ -80,9 +80,158 @@ func (d *Database) Migrate() error {
 					"rule_sets", "drivers", "driver_groups")
 			},
 		},
+		{
+			ID: "2026101701_driver_timeouts",
+			Migrate: func(tx *gorm.DB) error {
+				return addColumns(tx, &Driver{}, "Timeout", "IdleTimeout")
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return dropColumns(tx, &Driver{}, "Timeout", "IdleTimeout")
+			},
+		},
+		{
+			ID: "2026101702_driver_retry_policy",
+			Migrate: func(tx *gorm.DB) error {
+				return addColumns(tx, &Driver{}, "retry_max_attempts", "retry_delay", "retry_backoff", "retry_exit_codes")
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return dropColumns(tx, &Driver{}, "retry_max_attempts", "retry_delay", "retry_backoff", "retry_exit_codes")
+			},
+		},
+		{
+			ID: "2026101703_install_history",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&InstallRun{}, &InstallStep{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable(&InstallStep{}, &InstallRun{})
+			},
+		},
+		{
+			ID: "2026101704_driver_reboot_codes",
+			Migrate: func(tx *gorm.DB) error {
+				return addColumns(tx, &Driver{}, "RebootRtCodes")
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return dropColumns(tx, &Driver{}, "RebootRtCodes")
+			},
+		},
+		{
+			ID: "2026101705_driver_work_dir_env",
+			Migrate: func(tx *gorm.DB) error {
+				return addColumns(tx, &Driver{}, "WorkDir", "Env")
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return dropColumns(tx, &Driver{}, "WorkDir", "Env")
+			},
+		},
+		{
+			ID: "2026101706_driver_dependencies",
+			Migrate: func(tx *gorm.DB) error {
+				if tx.Migrator().HasTable("driver_dependencies") {
+					return nil
+				}
+				// Creates the join table of Driver.DependsOn
+				return tx.AutoMigrate(&Driver{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable("driver_dependencies")
+			},
+		},
+		{
+			ID: "2026101707_concurrency_limits",
+			Migrate: func(tx *gorm.DB) error {
+				if err := addColumns(tx, &DriverGroup{}, "MaxConcurrency"); err != nil {
+					return err
+				}
+				return addColumns(tx, &Driver{}, "Locks")
+			},
+			Rollback: func(tx *gorm.DB) error {
+				if err := dropColumns(tx, &Driver{}, "Locks"); err != nil {
+					return err
+				}
+				return dropColumns(tx, &DriverGroup{}, "MaxConcurrency")
+			},
+		},
+		{
+			ID: "2026101708_install_step_log_file",
+			Migrate: func(tx *gorm.DB) error {
+				return addColumns(tx, &InstallStep{}, "LogFile")
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return dropColumns(tx, &InstallStep{}, "LogFile")
+			},
+		},
+		{
+			ID: "2026101709_driver_archive_entry",
+			Migrate: func(tx *gorm.DB) error {
+				return addColumns(tx, &Driver{}, "ArchiveEntry")
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return dropColumns(tx, &Driver{}, "ArchiveEntry")
+			},
+		},
+		{
+			ID: "2026101710_driver_exit_code_notes",
+			Migrate: func(tx *gorm.DB) error {
+				return addColumns(tx, &Driver{}, "ExitCodeNotes")
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return dropColumns(tx, &Driver{}, "ExitCodeNotes")
+			},
+		},
+		{
+			ID: "2026101711_driver_output_patterns",
+			Migrate: func(tx *gorm.DB) error {
+				return addColumns(tx, &Driver{}, "SuccessPatterns", "FailurePatterns")
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return dropColumns(tx, &Driver{}, "SuccessPatterns", "FailurePatterns")
+			},
+		},
+		{
+			ID: "2026101712_driver_verify_check",
+			Migrate: func(tx *gorm.DB) error {
+				if err := addColumns(tx, &Driver{}, "verify_type", "verify_path", "verify_flags", "verify_pattern"); err != nil {
+					return err
+				}
+				return addColumns(tx, &InstallStep{}, "VerifyDetail")
+			},
+			Rollback: func(tx *gorm.DB) error {
+				if err := dropColumns(tx, &InstallStep{}, "VerifyDetail"); err != nil {
+					return err
+				}
+				return dropColumns(tx, &Driver{}, "verify_type", "verify_path", "verify_flags", "verify_pattern")
+			},
+		},
 	}).Migrate()
 }
 
+// addColumns adds the columns of fields to the table of model. Columns that
+// already exist are skipped, as a fresh database is created by the earlier
+// migrations from the current model.
+func addColumns(tx *gorm.DB, model any, fields ...string) error {
+	for _, field := range fields {
+		if tx.Migrator().HasColumn(model, field) {
+			continue
+		}
+		if err := tx.Migrator().AddColumn(model, field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dropColumns drops the columns of fields from the table of model.
+func dropColumns(tx *gorm.DB, model any, fields ...string) error {
+	for _, field := range fields {
+		if err := tx.Migrator().DropColumn(model, field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func openDB(path string) (*gorm.DB, error) {
 	db, err := gorm.Open(sqlite.Open(path), &gorm.Config{
 		Logger: logger.Default.LogMode(logger.Silent),
@@ -92,4 +241,4 @@ func openDB(path string) (*gorm.DB, error) {
 	}
 	db.Exec("PRAGMA foreign_keys = ON")
 	return db, nil
-}
\ No newline at end of file
+}
@@ -0,0 +1,35 @@
+package storage
+
+import "testing"
+
+// TestMigrate_AddsColumnsToExistingTable simulates a database created before a
+// column migration by dropping the columns and the migration record.
+func TestMigrate_AddsColumnsToExistingTable(t *testing.T) {
+	db := openTestDB(t)
+
+	if err := dropColumns(db.DB(), &Driver{}, "Timeout", "IdleTimeout"); err != nil {
+		t.Fatalf("dropColumns: %v", err)
+	}
+	if err := db.DB().Exec("DELETE FROM migrations WHERE id = ?", "2026101701_driver_timeouts").Error; err != nil {
+		t.Fatalf("delete migration record: %v", err)
+	}
+
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	for _, column := range []string{"Timeout", "IdleTimeout"} {
+		if !db.DB().Migrator().HasColumn(&Driver{}, column) {
+			t.Errorf("column %s was not added", column)
+		}
+	}
+}
+
+// TestMigrate_Idempotent verifies that running migrations on an up-to-date
+// database is a no-op.
+func TestMigrate_Idempotent(t *testing.T) {
+	db := openTestDB(t)
+
+	if err := db.Migrate(); err != nil {
+		t.Errorf("second Migrate: %v", err)
+	}
+}
@@ -3,6 +3,8 @@ package storage
 import (
 	"errors"
 	"fmt"
+	"regexp"
+	"slices"
 
 	"gorm.io/gorm"
 )
@@ -15,26 +17,75 @@ const (
 	Miscellaneous DriverType = "miscellaneous"
 )
 
+// RetryPolicy decides whether a failed driver command is run again.
+type RetryPolicy struct {
+	MaxAttempts int     `json:"maxAttempts"`                      // Total attempts including the first, 0 or 1 to disable
+	Delay       float32 `json:"delay"`                            // Seconds before the first retry
+	Backoff     float32 `json:"backoff"`                          // Factor applied to the delay after each retry, 0 or 1 for a fixed delay
+	ExitCodes   []int32 `json:"exitCodes" gorm:"serializer:json"` // Retryable exit codes, empty to retry any failure
+}
+
+type VerifyType string
+
+const (
+	VerifyNone    VerifyType = ""        // Nothing is verified
+	VerifyFile    VerifyType = "file"    // Path must exist
+	VerifyCommand VerifyType = "command" // Path run with Flags must exit with 0
+	VerifyOutput  VerifyType = "output"  // Output of Path run with Flags must match Pattern
+)
+
+// VerifyCheck confirms that a driver was installed once its command succeeded,
+// since some installers exit with 0 without installing anything.
+type VerifyCheck struct {
+	Type    VerifyType `json:"type"`
+	Path    string     `json:"path"`                         // File to check, or program to run
+	Flags   []string   `json:"flags" gorm:"serializer:json"` // Arguments of the program
+	Pattern string     `json:"pattern"`                      // Regex the output of the program must match
+}
+
+// ExitCodeNote is the meaning of an exit code of a driver, taking precedence
+// over the built-in exit code dictionary.
+type ExitCodeNote struct {
+	Code        int64  `json:"code"` // Compared as a 32-bit value, so HRESULTs may be entered signed or unsigned
+	Description string `json:"description"`
+	Hint        string `json:"hint"`
+}
+
 type DriverGroup struct {
 	Id                uint       `json:"id" gorm:"primaryKey;autoIncrement"`
 	Name              string     `json:"name"`
 	Type              DriverType `json:"type"`
 	MutuallyExclusive bool       `json:"mutuallyExclusive"`
+	MaxConcurrency    int        `json:"maxConcurrency"` // Drivers of the group running at once, 0 for no limit
 	Position          int        `json:"-" gorm:"index"`
 	Drivers           []*Driver  `json:"drivers" gorm:"foreignKey:GroupId;constraint:OnDelete:CASCADE"`
 }
 
 type Driver struct {
-	Id              uint       `json:"id" gorm:"primaryKey;autoIncrement"`
-	GroupId         uint       `json:"-" gorm:"index"`
-	Name            string     `json:"name"`
-	Type            DriverType `json:"type"`
-	Path            string     `json:"path"`
-	Flags           []string   `json:"flags" gorm:"serializer:json"`
-	MinExeTime      float32    `json:"minExeTime"`
-	AllowRtCodes    []int32    `json:"allowRtCodes" gorm:"serializer:json"`
-	Incompatibles   []*Driver  `json:"-" gorm:"many2many:driver_incompatibles;joinForeignKey:DriverID;joinReferences:IncompatibleDriverID;constraint:OnDelete:CASCADE"`
-	IncompatibleIds []uint     `json:"incompatibles" gorm:"-"`
+	Id              uint           `json:"id" gorm:"primaryKey;autoIncrement"`
+	GroupId         uint           `json:"-" gorm:"index"`
+	Name            string         `json:"name"`
+	Type            DriverType     `json:"type"`
+	Path            string         `json:"path"`
+	ArchiveEntry    string         `json:"archiveEntry"` // Installer inside the ZIP archive at Path, empty when Path is the installer
+	Flags           []string       `json:"flags" gorm:"serializer:json"`
+	WorkDir         string         `json:"workDir"`                    // Working directory of the command, the app's when empty
+	Env             []string       `json:"env" gorm:"serializer:json"` // Extra environment variables as KEY=value, overriding the app's
+	MinExeTime      float32        `json:"minExeTime"`
+	AllowRtCodes    []int32        `json:"allowRtCodes" gorm:"serializer:json"`
+	RebootRtCodes   []int32        `json:"rebootRtCodes" gorm:"serializer:json"`   // Exit codes meaning success with a reboot required, empty for 3010 and 1641
+	Timeout         float32        `json:"timeout"`                                // Seconds before the command is killed, 0 to disable
+	IdleTimeout     float32        `json:"idleTimeout"`                            // Seconds without output before the command is killed, 0 to disable
+	Locks           []string       `json:"locks" gorm:"serializer:json"`           // Named resources no other driver holding them may run with, e.g. "msi"
+	ExitCodeNotes   []ExitCodeNote `json:"exitCodeNotes" gorm:"serializer:json"`   // Meanings of the driver's own exit codes
+	SuccessPatterns []string       `json:"successPatterns" gorm:"serializer:json"` // Regexes of which one must match the output to succeed, empty to not require any
+	FailurePatterns []string       `json:"failurePatterns" gorm:"serializer:json"` // Regexes failing the command when matching the output, whatever its exit code
+	Retry           RetryPolicy    `json:"retry" gorm:"embedded;embeddedPrefix:retry_"`
+	Verify          VerifyCheck    `json:"verify" gorm:"embedded;embeddedPrefix:verify_"`
+	Incompatibles   []*Driver      `json:"-" gorm:"many2many:driver_incompatibles;joinForeignKey:DriverID;joinReferences:IncompatibleDriverID;constraint:OnDelete:CASCADE"`
+	IncompatibleIds []uint         `json:"incompatibles" gorm:"-"`
+	DependsOn       []*Driver      `json:"-" gorm:"many2many:driver_dependencies;joinForeignKey:DriverID;joinReferences:DependencyDriverID;constraint:OnDelete:CASCADE"`
+	DependsOnIds    []uint         `json:"dependsOn" gorm:"-"` // Drivers of any group that must be installed before this one
 }
 
 func populateIncompatibleIds(d *Driver) {
@@ -42,6 +93,10 @@ func populateIncompatibleIds(d *Driver) {
 	for i, inc := range d.Incompatibles {
 		d.IncompatibleIds[i] = inc.Id
 	}
+	d.DependsOnIds = make([]uint, len(d.DependsOn))
+	for i, dep := range d.DependsOn {
+		d.DependsOnIds[i] = dep.Id
+	}
 }
 
 type DriverGroupStorage struct {
@@ -54,7 +109,7 @@ func NewDriverGroupStorage(db *Database) *DriverGroupStorage {
 
 func (s *DriverGroupStorage) All() ([]DriverGroup, error) {
 	var groups []*DriverGroup
-	if err := s.db.DB().Preload("Drivers.Incompatibles").Order("position").Find(&groups).Error; err != nil {
+	if err := s.db.DB().Preload("Drivers.Incompatibles").Preload("Drivers.DependsOn").Order("position").Find(&groups).Error; err != nil {
 		return nil, err
 	}
 	result := make([]DriverGroup, len(groups))
@@ -69,7 +124,7 @@ func (s *DriverGroupStorage) All() ([]DriverGroup, error) {
 
 func (s *DriverGroupStorage) Get(id uint) (DriverGroup, error) {
 	var group DriverGroup
-	if err := s.db.DB().Preload("Drivers.Incompatibles").First(&group, id).Error; err != nil {
+	if err := s.db.DB().Preload("Drivers.Incompatibles").Preload("Drivers.DependsOn").First(&group, id).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return DriverGroup{}, fmt.Errorf("driver group: %w", ErrNotFound)
 		}
@@ -82,6 +137,9 @@ func (s *DriverGroupStorage) Get(id uint) (DriverGroup, error) {
 }
 
 func (s *DriverGroupStorage) Add(group DriverGroup) error {
+	if err := checkPatterns(group); err != nil {
+		return err
+	}
 	return s.db.DB().Transaction(func(tx *gorm.DB) error {
 		var maxPos int
 		tx.Model(&DriverGroup{}).Select("COALESCE(MAX(position), -1)").Scan(&maxPos)
@@ -91,6 +149,9 @@ func (s *DriverGroupStorage) Add(group DriverGroup) error {
 }
 
 func (s *DriverGroupStorage) Update(group DriverGroup) error {
+	if err := checkPatterns(group); err != nil {
+		return err
+	}
 	return s.db.DB().Transaction(func(tx *gorm.DB) error {
 		var existing []*Driver
 		if err := tx.Where("group_id = ?", group.Id).Find(&existing).Error; err != nil {
@@ -121,6 +182,7 @@ func (s *DriverGroupStorage) Update(group DriverGroup) error {
 			"name":               group.Name,
 			"type":               group.Type,
 			"mutually_exclusive": group.MutuallyExclusive,
+			"max_concurrency":    group.MaxConcurrency,
 		}).Error; err != nil {
 			return err
 		}
@@ -128,11 +190,11 @@ func (s *DriverGroupStorage) Update(group DriverGroup) error {
 		for _, d := range group.Drivers {
 			d.GroupId = group.Id
 			if d.Id == 0 {
-				if err := tx.Omit("Incompatibles").Create(d).Error; err != nil {
+				if err := tx.Omit("Incompatibles", "DependsOn").Create(d).Error; err != nil {
 					return err
 				}
 			} else {
-				if err := tx.Omit("Incompatibles").Save(d).Error; err != nil {
+				if err := tx.Omit("Incompatibles", "DependsOn").Save(d).Error; err != nil {
 					return err
 				}
 			}
@@ -143,12 +205,79 @@ func (s *DriverGroupStorage) Update(group DriverGroup) error {
 			if err := tx.Model(d).Association("Incompatibles").Replace(incompats); err != nil {
 				return err
 			}
+			deps := make([]*Driver, len(d.DependsOnIds))
+			for i, did := range d.DependsOnIds {
+				deps[i] = &Driver{Id: did}
+			}
+			if err := tx.Model(d).Association("DependsOn").Replace(deps); err != nil {
+				return err
+			}
 		}
 
-		return nil
+		return checkDependencyCycles(tx)
 	})
 }
 
+// checkPatterns returns ErrInvalidPattern naming the first success or failure
+// pattern of the group's drivers that does not compile.
+func checkPatterns(group DriverGroup) error {
+	for _, d := range group.Drivers {
+		for _, pattern := range slices.Concat(d.SuccessPatterns, d.FailurePatterns) {
+			if _, err := regexp.Compile(pattern); err != nil {
+				return fmt.Errorf("driver %s: %w %q: %v", d.Name, ErrInvalidPattern, pattern, err)
+			}
+		}
+	}
+	return nil
+}
+
+// checkDependencyCycles returns ErrDependencyCycle if any saved driver depends
+// on itself, directly or through other drivers.
+func checkDependencyCycles(tx *gorm.DB) error {
+	var edges []struct {
+		DriverID           uint
+		DependencyDriverID uint
+	}
+	if err := tx.Table("driver_dependencies").Find(&edges).Error; err != nil {
+		return err
+	}
+
+	deps := make(map[uint][]uint)
+	for _, e := range edges {
+		deps[e.DriverID] = append(deps[e.DriverID], e.DependencyDriverID)
+	}
+
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := make(map[uint]int)
+	var visit func(id uint) error
+	visit = func(id uint) error {
+		switch state[id] {
+		case visiting:
+			return fmt.Errorf("driver %d: %w", id, ErrDependencyCycle)
+		case visited:
+			return nil
+		}
+		state[id] = visiting
+		for _, dep := range deps[id] {
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		state[id] = visited
+		return nil
+	}
+
+	for id := range deps {
+		if err := visit(id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (s *DriverGroupStorage) Remove(id uint) error {
 	return s.db.DB().Transaction(func(tx *gorm.DB) error {
 		result := tx.Delete(&DriverGroup{}, id)
@@ -165,7 +294,7 @@ func (s *DriverGroupStorage) Remove(id uint) error {
 func (s *DriverGroupStorage) Clone(id uint) error {
 	return s.db.DB().Transaction(func(tx *gorm.DB) error {
 		var original DriverGroup
-		if err := tx.Preload("Drivers.Incompatibles").First(&original, id).Error; err != nil {
+		if err := tx.Preload("Drivers.Incompatibles").Preload("Drivers.DependsOn").First(&original, id).Error; err != nil {
 			if errors.Is(err, gorm.ErrRecordNotFound) {
 				return fmt.Errorf("driver group: %w", ErrNotFound)
 			}
@@ -179,6 +308,7 @@ func (s *DriverGroupStorage) Clone(id uint) error {
 			Name:              original.Name + " (copy)",
 			Type:              original.Type,
 			MutuallyExclusive: original.MutuallyExclusive,
+			MaxConcurrency:    original.MaxConcurrency,
 			Position:          maxPos + 1,
 		}
 		if err := tx.Omit("Drivers").Create(&newGroup).Error; err != nil {
@@ -188,13 +318,25 @@ func (s *DriverGroupStorage) Clone(id uint) error {
 		oldToNew := make(map[uint]*Driver, len(original.Drivers))
 		for _, d := range original.Drivers {
 			newDriver := &Driver{
-				GroupId:      newGroup.Id,
-				Name:         d.Name,
-				Type:         d.Type,
-				Path:         d.Path,
-				Flags:        d.Flags,
-				MinExeTime:   d.MinExeTime,
-				AllowRtCodes: d.AllowRtCodes,
+				GroupId:         newGroup.Id,
+				Name:            d.Name,
+				Type:            d.Type,
+				Path:            d.Path,
+				ArchiveEntry:    d.ArchiveEntry,
+				Flags:           d.Flags,
+				WorkDir:         d.WorkDir,
+				Env:             d.Env,
+				MinExeTime:      d.MinExeTime,
+				AllowRtCodes:    d.AllowRtCodes,
+				RebootRtCodes:   d.RebootRtCodes,
+				Timeout:         d.Timeout,
+				IdleTimeout:     d.IdleTimeout,
+				Locks:           d.Locks,
+				ExitCodeNotes:   d.ExitCodeNotes,
+				SuccessPatterns: d.SuccessPatterns,
+				FailurePatterns: d.FailurePatterns,
+				Retry:           d.Retry,
+				Verify:          d.Verify,
 			}
 			if err := tx.Create(newDriver).Error; err != nil {
 				return err
@@ -220,6 +362,23 @@ func (s *DriverGroupStorage) Clone(id uint) error {
 			}
 		}
 
+		// Dependencies within the group point to the copies, others are kept
+		for _, d := range original.Drivers {
+			if len(d.DependsOn) == 0 {
+				continue
+			}
+			newDeps := make([]*Driver, len(d.DependsOn))
+			for i, dep := range d.DependsOn {
+				newDeps[i] = dep
+				if mapped, ok := oldToNew[dep.Id]; ok {
+					newDeps[i] = mapped
+				}
+			}
+			if err := tx.Model(oldToNew[d.Id]).Association("DependsOn").Replace(newDeps); err != nil {
+				return err
+			}
+		}
+
 		return nil
 	})
 }
@@ -1,6 +1,9 @@
 package storage
 
 import (
+	"errors"
+	"slices"
+	"strings"
 	"testing"
 )
 
@@ -123,6 +126,7 @@ func TestDriverGroupStorage_Update_ScalarFields(t *testing.T) {
 	group.Name = "Updated"
 	group.Type = Display
 	group.MutuallyExclusive = true
+	group.MaxConcurrency = 3
 
 	if err := dgs.Update(group); err != nil {
 		t.Fatalf("Update: %v", err)
@@ -132,7 +136,7 @@ func TestDriverGroupStorage_Update_ScalarFields(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Get after update: %v", err)
 	}
-	if updated.Name != "Updated" || updated.Type != Display || !updated.MutuallyExclusive {
+	if updated.Name != "Updated" || updated.Type != Display || !updated.MutuallyExclusive || updated.MaxConcurrency != 3 {
 		t.Errorf("unexpected updated values: %+v", updated)
 	}
 }
@@ -389,3 +393,137 @@ func TestDriverGroupStorage_CompleteWorkflow(t *testing.T) {
 		t.Errorf("expected 1 group after remove, got %d", len(all))
 	}
 }
+
+func TestDriverGroupStorage_Clone_CopiesDriverFields(t *testing.T) {
+	db := openTestDB(t)
+	dgs := NewDriverGroupStorage(db)
+
+	id := addGroup(t, dgs, DriverGroup{Name: "Chipset", Type: Miscellaneous, MaxConcurrency: 2,
+		Drivers: []*Driver{{Name: "Setup", Path: "chipset.zip", ArchiveEntry: "setup.exe", Flags: []string{"/s"}, WorkDir: "Chipset", Env: []string{"A=1"}, Locks: []string{"msi"},
+			Timeout: 600, IdleTimeout: 120, RebootRtCodes: []int32{194}, ExitCodeNotes: []ExitCodeNote{{Code: 2, Description: "No device"}},
+			SuccessPatterns: []string{"(?i)success"}, FailurePatterns: []string{"No supported device"},
+			Verify: VerifyCheck{Type: VerifyOutput, Path: "reg", Flags: []string{"query", "HKLM"}, Pattern: "Chipset"},
+			Retry:  RetryPolicy{MaxAttempts: 2, Delay: 5, ExitCodes: []int32{1603}}}}})
+
+	if err := dgs.Clone(id); err != nil {
+		t.Fatalf("Clone: %v", err)
+	}
+
+	all, _ := dgs.All()
+	clone := all[len(all)-1]
+	if clone.Id == id || len(clone.Drivers) != 1 || clone.MaxConcurrency != 2 {
+		t.Fatalf("unexpected clone: %+v", clone)
+	}
+	if d := clone.Drivers[0]; d.Path != "chipset.zip" || d.ArchiveEntry != "setup.exe" || d.Timeout != 600 || d.IdleTimeout != 120 ||
+		d.Retry.MaxAttempts != 2 || len(d.Retry.ExitCodes) != 1 || len(d.RebootRtCodes) != 1 ||
+		d.WorkDir != "Chipset" || len(d.Env) != 1 || len(d.Locks) != 1 || len(d.ExitCodeNotes) != 1 ||
+		len(d.SuccessPatterns) != 1 || len(d.FailurePatterns) != 1 ||
+		d.Verify.Type != VerifyOutput || len(d.Verify.Flags) != 2 || d.Verify.Pattern != "Chipset" {
+		t.Errorf("driver fields not copied: %+v", d)
+	}
+}
+
+// ==================== Dependencies ====================
+
+func TestDriverGroupStorage_Update_DependsOnAcrossGroups(t *testing.T) {
+	db := openTestDB(t)
+	dgs := NewDriverGroupStorage(db)
+
+	chipsetId := addGroup(t, dgs, DriverGroup{Name: "Chipset", Type: Miscellaneous, Drivers: []*Driver{{Name: "Chipset"}}})
+	audioId := addGroup(t, dgs, DriverGroup{Name: "Audio", Type: Miscellaneous, Drivers: []*Driver{{Name: "Audio"}}})
+
+	chipset, _ := dgs.Get(chipsetId)
+	audio, _ := dgs.Get(audioId)
+	audio.Drivers[0].DependsOnIds = []uint{chipset.Drivers[0].Id}
+	if err := dgs.Update(audio); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	got, _ := dgs.Get(audioId)
+	if deps := got.Drivers[0].DependsOnIds; len(deps) != 1 || deps[0] != chipset.Drivers[0].Id {
+		t.Errorf("DependsOnIds: got %v, want [%d]", deps, chipset.Drivers[0].Id)
+	}
+}
+
+func TestDriverGroupStorage_Update_RejectsDependencyCycle(t *testing.T) {
+	db := openTestDB(t)
+	dgs := NewDriverGroupStorage(db)
+
+	g1Id := addGroup(t, dgs, DriverGroup{Name: "G1", Type: Miscellaneous, Drivers: []*Driver{{Name: "A"}}})
+	g2Id := addGroup(t, dgs, DriverGroup{Name: "G2", Type: Miscellaneous, Drivers: []*Driver{{Name: "B"}, {Name: "C"}}})
+
+	g1, _ := dgs.Get(g1Id)
+	g2, _ := dgs.Get(g2Id)
+	a, b, c := g1.Drivers[0], g2.Drivers[0], g2.Drivers[1]
+
+	// C -> B -> A
+	b.DependsOnIds = []uint{a.Id}
+	c.DependsOnIds = []uint{b.Id}
+	if err := dgs.Update(g2); err != nil {
+		t.Fatalf("Update without cycle: %v", err)
+	}
+
+	// A -> C closes the cycle
+	a.DependsOnIds = []uint{c.Id}
+	if err := dgs.Update(g1); !errors.Is(err, ErrDependencyCycle) {
+		t.Fatalf("expected ErrDependencyCycle, got %v", err)
+	}
+	if got, _ := dgs.Get(g1Id); len(got.Drivers[0].DependsOnIds) != 0 {
+		t.Errorf("rejected dependencies should not be saved, got %v", got.Drivers[0].DependsOnIds)
+	}
+
+	// A driver depending on itself
+	g1.Drivers[0].DependsOnIds = []uint{a.Id}
+	if err := dgs.Update(g1); !errors.Is(err, ErrDependencyCycle) {
+		t.Errorf("expected ErrDependencyCycle for a self-dependency, got %v", err)
+	}
+}
+
+func TestDriverGroupStorage_Clone_RemapsDependencies(t *testing.T) {
+	db := openTestDB(t)
+	dgs := NewDriverGroupStorage(db)
+
+	baseId := addGroup(t, dgs, DriverGroup{Name: "Base", Type: Miscellaneous, Drivers: []*Driver{{Name: "Base"}}})
+	id := addGroup(t, dgs, DriverGroup{Name: "G", Type: Miscellaneous, Drivers: []*Driver{{Name: "A"}, {Name: "B"}}})
+
+	base, _ := dgs.Get(baseId)
+	group, _ := dgs.Get(id)
+	group.Drivers[1].DependsOnIds = []uint{group.Drivers[0].Id, base.Drivers[0].Id}
+	if err := dgs.Update(group); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	if err := dgs.Clone(id); err != nil {
+		t.Fatalf("Clone: %v", err)
+	}
+	all, _ := dgs.All()
+	clone := all[len(all)-1]
+
+	deps := clone.Drivers[1].DependsOnIds
+	if len(deps) != 2 || !slices.Contains(deps, clone.Drivers[0].Id) || !slices.Contains(deps, base.Drivers[0].Id) {
+		t.Errorf("cloned dependencies: got %v, want [%d %d]", deps, clone.Drivers[0].Id, base.Drivers[0].Id)
+	}
+}
+
+// ==================== Output patterns ====================
+
+func TestDriverGroupStorage_Update_RejectsInvalidPattern(t *testing.T) {
+	db := openTestDB(t)
+	dgs := NewDriverGroupStorage(db)
+
+	id := addGroup(t, dgs, DriverGroup{Name: "LAN", Type: Network, Drivers: []*Driver{{Name: "Intel", SuccessPatterns: []string{"(?i)success"}}}})
+
+	group, _ := dgs.Get(id)
+	group.Drivers[0].FailurePatterns = []string{"No supported device", "error ("}
+	err := dgs.Update(group)
+	if !errors.Is(err, ErrInvalidPattern) || !strings.Contains(err.Error(), `"error ("`) {
+		t.Fatalf("expected ErrInvalidPattern naming the pattern, got %v", err)
+	}
+	if got, _ := dgs.Get(id); len(got.Drivers[0].FailurePatterns) != 0 {
+		t.Errorf("rejected patterns should not be saved, got %v", got.Drivers[0].FailurePatterns)
+	}
+
+	if err := dgs.Add(DriverGroup{Name: "Audio", Type: Miscellaneous, Drivers: []*Driver{{Name: "Realtek", SuccessPatterns: []string{"["}}}}); !errors.Is(err, ErrInvalidPattern) {
+		t.Errorf("Add: expected ErrInvalidPattern, got %v", err)
+	}
+}
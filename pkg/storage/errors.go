@@ -3,4 +3,12 @@ package storage
 import "errors"
 
 // ErrNotFound is returned when a storage operation finds no matching record.
-var ErrNotFound = errors.New("storage: not found")
\ No newline at end of file
+var ErrNotFound = errors.New("storage: not found")
+
+// ErrDependencyCycle is returned when saving drivers that depend on each other
+// directly or indirectly.
+var ErrDependencyCycle = errors.New("storage: dependency cycle")
+
+// ErrInvalidPattern is returned when saving a driver with an output pattern
+// that is not a valid regular expression.
+var ErrInvalidPattern = errors.New("storage: invalid output pattern")
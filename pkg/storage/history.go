@@ -0,0 +1,149 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"install-it/pkg/status"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// InstallRun is a recorded install session on a machine.
+type InstallRun struct {
+	Id         uint           `json:"id" gorm:"primaryKey;autoIncrement"`
+	Machine    string         `json:"machine" gorm:"index"`
+	Status     status.Status  `json:"status"`
+	StartedAt  time.Time      `json:"startedAt" gorm:"index"`
+	FinishedAt time.Time      `json:"finishedAt"`
+	Steps      []*InstallStep `json:"steps" gorm:"foreignKey:RunId;constraint:OnDelete:CASCADE"`
+}
+
+// InstallStep is a recorded command of an InstallRun.
+type InstallStep struct {
+	Id           uint          `json:"id" gorm:"primaryKey;autoIncrement"`
+	RunId        uint          `json:"runId" gorm:"index"`
+	DriverId     uint          `json:"driverId" gorm:"index"` // 0 for setting tasks
+	Name         string        `json:"name"`
+	GroupName    string        `json:"groupName"`
+	CommandLine  string        `json:"commandLine"`
+	ExitCode     int           `json:"exitCode"`
+	Lapse        float32       `json:"lapse"`
+	Status       status.Status `json:"status"`
+	OutputTail   string        `json:"outputTail"`   // Last part of the decoded output
+	LogFile      string        `json:"logFile"`      // Reference of the full output, see execute.LogStore
+	VerifyDetail string        `json:"verifyDetail"` // Outcome of the verification check, empty if the driver has none
+	StartedAt    time.Time     `json:"startedAt"`
+	FinishedAt   time.Time     `json:"finishedAt"`
+}
+
+// HistoryFilter narrows the runs returned by InstallHistoryStorage.List.
+// Zero values match everything.
+type HistoryFilter struct {
+	Machine  string        `json:"machine"`
+	Status   status.Status `json:"status"`
+	DriverId uint          `json:"driverId"` // Runs with a step of this driver
+	Since    time.Time     `json:"since"`
+	Until    time.Time     `json:"until"`
+	Limit    int           `json:"limit"`
+}
+
+type InstallHistoryStorage struct {
+	db *Database
+}
+
+func NewInstallHistoryStorage(db *Database) *InstallHistoryStorage {
+	return &InstallHistoryStorage{db: db}
+}
+
+// StartRun records a new running InstallRun and returns its id.
+func (s *InstallHistoryStorage) StartRun(machine string, startedAt time.Time) (uint, error) {
+	run := InstallRun{Machine: machine, Status: status.Running, StartedAt: startedAt}
+	if err := s.db.DB().Create(&run).Error; err != nil {
+		return 0, err
+	}
+	return run.Id, nil
+}
+
+// AddStep records a finished step of the run.
+func (s *InstallHistoryStorage) AddStep(runId uint, step InstallStep) error {
+	step.Id, step.RunId = 0, runId
+	return s.db.DB().Create(&step).Error
+}
+
+// FinishRun records the final status of the run.
+func (s *InstallHistoryStorage) FinishRun(runId uint, runStatus status.Status, finishedAt time.Time) error {
+	result := s.db.DB().Model(&InstallRun{}).Where("id = ?", runId).Updates(map[string]any{
+		"status":      runStatus,
+		"finished_at": finishedAt,
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("install run: %w", ErrNotFound)
+	}
+	return nil
+}
+
+// List returns the runs matching filter with their steps, newest first.
+func (s *InstallHistoryStorage) List(filter HistoryFilter) ([]InstallRun, error) {
+	query := s.db.DB().Preload("Steps").Order("started_at DESC, id DESC")
+	if filter.Machine != "" {
+		query = query.Where("machine = ?", filter.Machine)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.DriverId != 0 {
+		query = query.Where("id IN (?)", s.db.DB().Model(&InstallStep{}).Select("run_id").Where("driver_id = ?", filter.DriverId))
+	}
+	if !filter.Since.IsZero() {
+		query = query.Where("started_at >= ?", filter.Since)
+	}
+	if !filter.Until.IsZero() {
+		query = query.Where("started_at < ?", filter.Until)
+	}
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+
+	runs := []InstallRun{}
+	if err := query.Find(&runs).Error; err != nil {
+		return nil, err
+	}
+	return runs, nil
+}
+
+func (s *InstallHistoryStorage) Get(id uint) (InstallRun, error) {
+	var run InstallRun
+	if err := s.db.DB().Preload("Steps").First(&run, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return InstallRun{}, fmt.Errorf("install run: %w", ErrNotFound)
+		}
+		return InstallRun{}, err
+	}
+	return run, nil
+}
+
+// Machines returns the distinct machines with recorded runs.
+func (s *InstallHistoryStorage) Machines() ([]string, error) {
+	machines := []string{}
+	if err := s.db.DB().Model(&InstallRun{}).Distinct("machine").Order("machine").Pluck("machine", &machines).Error; err != nil {
+		return nil, err
+	}
+	return machines, nil
+}
+
+func (s *InstallHistoryStorage) Remove(id uint) error {
+	return s.db.DB().Transaction(func(tx *gorm.DB) error {
+		result := tx.Delete(&InstallRun{}, id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrNotFound
+		}
+		return nil
+	})
+}
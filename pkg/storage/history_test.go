@@ -0,0 +1,156 @@
+package storage
+
+import (
+	"errors"
+	"install-it/pkg/status"
+	"testing"
+	"time"
+)
+
+// addRun records a finished run with steps of the given driver ids.
+func addRun(t *testing.T, hs *InstallHistoryStorage, machine string, startedAt time.Time, runStatus status.Status, driverIds ...uint) uint {
+	t.Helper()
+	id, err := hs.StartRun(machine, startedAt)
+	if err != nil {
+		t.Fatalf("StartRun: %v", err)
+	}
+	for _, driverId := range driverIds {
+		if err := hs.AddStep(id, InstallStep{DriverId: driverId, Status: runStatus}); err != nil {
+			t.Fatalf("AddStep: %v", err)
+		}
+	}
+	if err := hs.FinishRun(id, runStatus, startedAt.Add(time.Minute)); err != nil {
+		t.Fatalf("FinishRun: %v", err)
+	}
+	return id
+}
+
+func TestInstallHistoryStorage_RecordAndGet(t *testing.T) {
+	hs := NewInstallHistoryStorage(openTestDB(t))
+
+	startedAt := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	id, err := hs.StartRun("PC-01", startedAt)
+	if err != nil {
+		t.Fatalf("StartRun: %v", err)
+	}
+
+	run, err := hs.Get(id)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if run.Status != status.Running || run.Machine != "PC-01" {
+		t.Errorf("unexpected running run: %+v", run)
+	}
+
+	step := InstallStep{
+		DriverId:     7,
+		Name:         "Audio",
+		GroupName:    "Realtek",
+		CommandLine:  `setup.exe /s`,
+		ExitCode:     3010,
+		Lapse:        12.5,
+		Status:       status.Completed,
+		OutputTail:   "done",
+		LogFile:      "20261017-120000/7_Audio.log",
+		VerifyDetail: "Audio.dll exists",
+	}
+	if err := hs.AddStep(id, step); err != nil {
+		t.Fatalf("AddStep: %v", err)
+	}
+	if err := hs.FinishRun(id, status.Completed, startedAt.Add(time.Minute)); err != nil {
+		t.Fatalf("FinishRun: %v", err)
+	}
+
+	run, err = hs.Get(id)
+	if err != nil {
+		t.Fatalf("Get after finish: %v", err)
+	}
+	if run.Status != status.Completed || !run.FinishedAt.Equal(startedAt.Add(time.Minute)) {
+		t.Errorf("unexpected finished run: %+v", run)
+	}
+	if len(run.Steps) != 1 {
+		t.Fatalf("expected 1 step, got %d", len(run.Steps))
+	}
+	if got := run.Steps[0]; got.DriverId != 7 || got.ExitCode != 3010 || got.CommandLine != step.CommandLine || got.OutputTail != "done" || got.LogFile != step.LogFile ||
+		got.VerifyDetail != step.VerifyDetail {
+		t.Errorf("unexpected step: %+v", got)
+	}
+}
+
+func TestInstallHistoryStorage_FinishRun_NotFound(t *testing.T) {
+	hs := NewInstallHistoryStorage(openTestDB(t))
+
+	if err := hs.FinishRun(42, status.Completed, time.Now()); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestInstallHistoryStorage_List_Filters(t *testing.T) {
+	hs := NewInstallHistoryStorage(openTestDB(t))
+
+	day := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	first := addRun(t, hs, "PC-01", day, status.Completed, 1, 2)
+	second := addRun(t, hs, "PC-02", day.Add(24*time.Hour), status.Failed, 2)
+	third := addRun(t, hs, "PC-01", day.Add(48*time.Hour), status.Failed, 3)
+
+	tests := []struct {
+		name   string
+		filter HistoryFilter
+		want   []uint
+	}{
+		{"all newest first", HistoryFilter{}, []uint{third, second, first}},
+		{"machine", HistoryFilter{Machine: "PC-01"}, []uint{third, first}},
+		{"status", HistoryFilter{Status: status.Failed}, []uint{third, second}},
+		{"driver", HistoryFilter{DriverId: 2}, []uint{second, first}},
+		{"time range", HistoryFilter{Since: day.Add(time.Hour), Until: day.Add(48 * time.Hour)}, []uint{second}},
+		{"limit", HistoryFilter{Limit: 1}, []uint{third}},
+	}
+
+	for _, tt := range tests {
+		runs, err := hs.List(tt.filter)
+		if err != nil {
+			t.Fatalf("%s: List: %v", tt.name, err)
+		}
+		got := make([]uint, len(runs))
+		for i, run := range runs {
+			got[i] = run.Id
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+				break
+			}
+		}
+	}
+
+	machines, err := hs.Machines()
+	if err != nil {
+		t.Fatalf("Machines: %v", err)
+	}
+	if len(machines) != 2 || machines[0] != "PC-01" || machines[1] != "PC-02" {
+		t.Errorf("Machines: got %v", machines)
+	}
+}
+
+func TestInstallHistoryStorage_Remove_CascadesSteps(t *testing.T) {
+	db := openTestDB(t)
+	hs := NewInstallHistoryStorage(db)
+
+	id := addRun(t, hs, "PC-01", time.Now(), status.Completed, 1, 2)
+	if err := hs.Remove(id); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+
+	var count int64
+	db.DB().Model(&InstallStep{}).Count(&count)
+	if count != 0 {
+		t.Errorf("expected steps to be removed, %d left", count)
+	}
+	if err := hs.Remove(id); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound for removed run, got %v", err)
+	}
+}
@@ -1,9 +1,9 @@
 package update
 
 import (
-	"archive/zip"
 	"encoding/json"
 	"fmt"
+	"install-it/pkg/archive"
 	"io"
 	"net/http"
 	"os"
@@ -224,36 +224,5 @@ func (u *Updater) CheckAndApplyUpdates() {
 }
 
 func extractZipToDir(zipPath, destDir string) error {
-	r, err := zip.OpenReader(zipPath)
-	if err != nil {
-		return err
-	}
-	defer r.Close()
-
-	cleanDest := filepath.Clean(destDir) + string(os.PathSeparator)
-
-	for _, f := range r.File {
-		target := filepath.Join(destDir, filepath.FromSlash(f.Name))
-		if !strings.HasPrefix(target, cleanDest) {
-			return fmt.Errorf("zip slip detected: %s", f.Name)
-		}
-
-		if f.FileInfo().IsDir() {
-			os.MkdirAll(target, f.Mode())
-			continue
-		}
-
-		os.MkdirAll(filepath.Dir(target), os.ModePerm)
-		rc, err := f.Open()
-		if err != nil {
-			return err
-		}
-
-		if out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, f.Mode()); err == nil {
-			io.Copy(out, rc)
-			out.Close()
-		}
-		rc.Close()
-	}
-	return nil
+	return archive.Extract(zipPath, destDir)
 }